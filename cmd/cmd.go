@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"bufio"
+	"encoding/csv"
 	"fmt"
 	"io"
 	"log"
+	"math"
 	"os"
+	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mpolden/journal/journal"
@@ -15,7 +21,7 @@ import (
 
 // Options represents command line options that are shared across sub-commands.
 type Options struct {
-	Config string `short:"f" long:"config" description:"Config file" value-name:"FILE" default:"~/.journalrc"`
+	Config string `short:"f" long:"config" description:"Config file. The default prefers $XDG_CONFIG_HOME/journal/config.toml if it exists" value-name:"FILE" default:"~/.journalrc"`
 	Color  string `short:"c" long:"color" description:"When to use colors in output. Default is to use colors if stdout is a TTY" default:"auto" choice:"always" choice:"never" choice:"auto"`
 	IsPipe bool
 	Writer io.Writer
@@ -35,33 +41,219 @@ type Import struct {
 // Export represents options for the export sub-command.
 type Export struct {
 	Options
-	Since string `short:"s" long:"since" description:"Print records since this date" value-name:"YYYY-MM-DD"`
-	Until string `short:"u" long:"until" description:"Print records until this date" value-name:"YYYY-MM-DD"`
-	Args  struct {
-		Account string `description:"Account number" positional-arg-name:"account-number"`
+	Since           string   `short:"s" long:"since" description:"Print records since this date" value-name:"YYYY-MM-DD"`
+	Until           string   `short:"u" long:"until" description:"Print records until this date" value-name:"YYYY-MM-DD"`
+	Depth           int      `short:"d" long:"depth" description:"Collapse groups nested deeper than N. Defaults to exporting all levels" value-name:"N"`
+	ExcludeAccounts []string `short:"x" long:"exclude-account" description:"Exclude account number or account group" value-name:"ACCOUNT"`
+	Args            struct {
+		Accounts []string `description:"Account number or account group" positional-arg-name:"account"`
 	} `positional-args:"yes"`
 }
 
 // Accounts reprents options for the acct sub-command
 type Accounts struct {
 	Options
+	All bool `short:"a" long:"all" description:"Show closed accounts"`
+}
+
+// RenameAccount represents options for the acct rename sub-command.
+type RenameAccount struct {
+	Options
+	Args struct {
+		Number string `description:"Account number" positional-arg-name:"account-number" required:"yes"`
+		Name   string `description:"New account name. Defaults to the name declared in config" positional-arg-name:"name"`
+	} `positional-args:"yes"`
+}
+
+// RemoveAccount represents options for the acct rm sub-command.
+type RemoveAccount struct {
+	Options
+	Purge bool `short:"p" long:"purge" description:"Also remove records belonging to the account"`
+	Args  struct {
+		Number string `description:"Account number" positional-arg-name:"account-number"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// MergeAccounts represents options for the acct merge sub-command.
+type MergeAccounts struct {
+	Options
+	Args struct {
+		From string `description:"Account number to move records from" positional-arg-name:"from-account-number"`
+		To   string `description:"Account number to move records to" positional-arg-name:"to-account-number"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// ListGroups represents options for the group ls sub-command.
+type ListGroups struct {
+	Options
+}
+
+// AddGroup represents options for the group add sub-command.
+type AddGroup struct {
+	Options
+	Args struct {
+		Name string `description:"Group name" positional-arg-name:"name"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// RemoveGroup represents options for the group rm sub-command.
+type RemoveGroup struct {
+	Options
+	Args struct {
+		Name string `description:"Group name" positional-arg-name:"name"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// PinRecord represents options for the group pin sub-command.
+type PinRecord struct {
+	Options
+	Args struct {
+		Group string `description:"Group name" positional-arg-name:"group"`
+		ID    string `description:"Record ID" positional-arg-name:"record-id"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// UnpinRecord represents options for the group unpin sub-command.
+type UnpinRecord struct {
+	Options
+	Args struct {
+		ID string `description:"Record ID" positional-arg-name:"record-id"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// AddPattern represents options for the group pattern add sub-command.
+type AddPattern struct {
+	Options
+	Args struct {
+		Group   string `description:"Group name" positional-arg-name:"group"`
+		Pattern string `description:"Regular expression matching record text" positional-arg-name:"pattern"`
+	} `positional-args:"yes" required:"yes"`
 }
 
 // List represents options for the export sub-command.
 type List struct {
 	Options
-	Explain    string   `short:"e" long:"explain" optional:"yes" optional-value:"all" value-name:"GROUP" description:"Print records in GROUP. Defaults to all groups"`
-	Since      string   `short:"s" long:"since" description:"Print records since this date" value-name:"YYYY-MM-DD"`
-	Until      string   `short:"u" long:"until" description:"Print records until this date" value-name:"YYYY-MM-DD"`
-	Month      int      `short:"m" long:"month" description:"Print records in this month of the current year" value-name:"M"`
-	OrderBy    string   `short:"o" long:"order" description:"Print records ordered by a specific field" choice:"sum" choice:"date" choice:"group" choice:"text" default:"sum"`
-	HideGroups []string `short:"H" long:"hide" description:"Hide group by name" value-name:"NAME"`
-	All        bool     `short:"a" long:"all" description:"Show records that would otherwise be discarded by group config"`
-	Args       struct {
-		Account string `description:"Only print records for given account number" positional-arg-name:"account-number"`
+	Explain         string   `short:"e" long:"explain" optional:"yes" optional-value:"all" value-name:"GROUP" description:"Print records in GROUP, including its nested groups. Defaults to all groups"`
+	Since           string   `short:"s" long:"since" description:"Print records since this date" value-name:"YYYY-MM-DD"`
+	Until           string   `short:"u" long:"until" description:"Print records until this date" value-name:"YYYY-MM-DD"`
+	Month           int      `short:"m" long:"month" description:"Print records in this month of the current year" value-name:"M"`
+	OrderBy         string   `short:"o" long:"order" description:"Print records ordered by a specific field" choice:"sum" choice:"date" choice:"group" choice:"text" default:"sum"`
+	HideGroups      []string `short:"H" long:"hide" description:"Hide group, including its nested groups, by name" value-name:"NAME"`
+	Depth           int      `short:"d" long:"depth" description:"Collapse groups nested deeper than N. Defaults to showing all levels" value-name:"N"`
+	All             bool     `short:"a" long:"all" description:"Show records that would otherwise be discarded by group config, are transfers or belong to closed accounts"`
+	Type            string   `short:"t" long:"type" description:"Only print records for accounts of this type" choice:"checking" choice:"savings" choice:"creditcard" choice:"loan"`
+	ExcludeAccounts []string `short:"x" long:"exclude-account" description:"Exclude account number or account group" value-name:"ACCOUNT"`
+	Prorate         bool     `short:"p" long:"prorate" description:"Prorate monthly budgets by the number of days in the time range, and print the projected sum at the end of the month"`
+	Args            struct {
+		Accounts []string `description:"Only print records for given account numbers or account groups" positional-arg-name:"account"`
 	} `positional-args:"yes"`
 }
 
+// ExplainRule represents options for the rules explain sub-command.
+type ExplainRule struct {
+	Options
+	Account string `long:"account" description:"Account number to use when explaining text" value-name:"NUMBER"`
+	Amount  int64  `long:"amount" description:"Amount, in one-hundredth of the currency, to use when explaining text" value-name:"AMOUNT"`
+	Date    string `long:"date" description:"Date to use when explaining text. Defaults to today" value-name:"YYYY-MM-DD"`
+	Args    struct {
+		Record string `description:"ID of a stored record, or text to explain" positional-arg-name:"id-or-text"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// LintRules represents options for the rules lint sub-command.
+type LintRules struct {
+	Options
+}
+
+// CheckConfig represents options for the config check sub-command.
+type CheckConfig struct {
+	Options
+}
+
+// SuggestRules represents options for the rules suggest sub-command.
+type SuggestRules struct {
+	Options
+	Since    string `short:"s" long:"since" description:"Only consider records since this date" value-name:"YYYY-MM-DD"`
+	Until    string `short:"u" long:"until" description:"Only consider records until this date" value-name:"YYYY-MM-DD"`
+	MinCount int    `short:"n" long:"min-count" description:"Minimum number of records in a suggestion" value-name:"N" default:"2"`
+	Limit    int    `short:"l" long:"limit" description:"Maximum number of suggestions to print. 0 means no limit" value-name:"N" default:"20"`
+	TOML     bool   `short:"t" long:"toml" description:"Print suggestions as TOML groups"`
+}
+
+// Classify represents options for the classify sub-command.
+type Classify struct {
+	Options
+	Since string  `short:"s" long:"since" description:"Only classify records since this date" value-name:"YYYY-MM-DD"`
+	Until string  `short:"u" long:"until" description:"Only classify records until this date" value-name:"YYYY-MM-DD"`
+	Min   float64 `short:"m" long:"min-confidence" description:"Only print proposals with at least this confidence" value-name:"CONFIDENCE" default:"0"`
+}
+
+// Categorize represents options for the categorize sub-command.
+type Categorize struct {
+	Options
+	Since string `short:"s" long:"since" description:"Only categorize records since this date" value-name:"YYYY-MM-DD"`
+	Until string `short:"u" long:"until" description:"Only categorize records until this date" value-name:"YYYY-MM-DD"`
+	Input io.Reader
+}
+
+// Allocate represents options for the allocate sub-command.
+type Allocate struct {
+	Options
+	Args struct {
+		Month  string `description:"Month to allocate in" positional-arg-name:"YYYY-MM"`
+		Group  string `description:"Group to allocate to" positional-arg-name:"group"`
+		Amount int64  `description:"Amount to allocate, in one-hundredth of the currency. Zero removes the allocation" positional-arg-name:"amount"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+// Goals represents options for the goals sub-command.
+type Goals struct {
+	Options
+	Date string `long:"date" description:"Show progress at this date. Defaults to today" value-name:"YYYY-MM-DD"`
+}
+
+// Alert represents options for the alert sub-command.
+type Alert struct {
+	Options
+	Date      string `long:"date" description:"Evaluate budgets at this date. Defaults to today" value-name:"YYYY-MM-DD"`
+	Quiet     bool   `short:"q" long:"quiet" description:"Don't send notifications to the configured webhook and command"`
+	Anomalies bool   `short:"A" long:"anomalies" description:"Also report anomalies in the current month"`
+}
+
+// Transfers represents options for the transfers sub-command.
+type Transfers struct {
+	Options
+	Since string `short:"s" long:"since" description:"Only list transfers since this date" value-name:"YYYY-MM-DD"`
+	Until string `short:"u" long:"until" description:"Only list transfers until this date" value-name:"YYYY-MM-DD"`
+}
+
+// Recurring represents options for the recurring sub-command.
+type Recurring struct {
+	Options
+	Since    string `short:"s" long:"since" description:"Only consider records since this date" value-name:"YYYY-MM-DD"`
+	Until    string `short:"u" long:"until" description:"Only consider records until this date" value-name:"YYYY-MM-DD"`
+	MinCount int    `short:"n" long:"min-count" description:"Minimum number of charges in a recurring payment" value-name:"N" default:"3"`
+}
+
+// Forecast represents options for the forecast sub-command.
+type Forecast struct {
+	Options
+	Months int    `short:"m" long:"months" description:"Number of months to forecast after the current month" value-name:"N" default:"6"`
+	Daily  bool   `short:"d" long:"daily" description:"Display balance for each day instead of each month"`
+	CSV    bool   `long:"csv" description:"Write forecast as CSV"`
+	Date   string `long:"date" description:"Start forecast at this date. Defaults to today" value-name:"YYYY-MM-DD"`
+}
+
+// Anomalies represents options for the anomalies sub-command.
+type Anomalies struct {
+	Options
+	Since     string  `short:"s" long:"since" description:"Only report anomalies since this date. Defaults to start of month" value-name:"YYYY-MM-DD"`
+	Until     string  `short:"u" long:"until" description:"Only report anomalies until this date. Defaults to today" value-name:"YYYY-MM-DD"`
+	Months    int     `short:"m" long:"months" description:"Number of months of history to compare with" value-name:"N" default:"12"`
+	Threshold float64 `short:"t" long:"threshold" description:"Minimum modified z-score of an anomaly" value-name:"SCORE" default:"3.5"`
+	All       bool    `short:"a" long:"all" description:"Include records that would otherwise be discarded by group config or are transfers"`
+}
+
 // NewLogger creates a new preconfigured logger.
 func NewLogger(w io.Writer) *log.Logger { return log.New(w, "journal: ", 0) }
 
@@ -98,6 +290,9 @@ func (i *Import) Execute(args []string) error {
 		writes, err := j.Write(i.Args.Account, rs)
 		i.Log.Printf("created %d new account(s)", writes.Account)
 		i.Log.Printf("imported %d new record(s) out of %d total", writes.Record, len(rs))
+		if writes.Transfer > 0 {
+			i.Log.Printf("detected %d new transfer(s)", writes.Transfer)
+		}
 		if err != nil {
 			return err
 		}
@@ -119,17 +314,159 @@ func (a *Accounts) Execute(args []string) error {
 	}
 
 	table := tablewriter.NewWriter(a.Writer)
-	table.SetHeader([]string{"Number", "Name", "Records"})
+	table.SetHeader([]string{"Number", "Name", "Institution", "Type", "Class", "Records", "Balance"})
 	table.SetAutoWrapText(false)
-	for _, a := range as {
+	table.SetColumnAlignment([]int{
+		0, 0, 0, 0, 0, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT,
+	})
+	for _, acct := range as {
+		if acct.Closed && !a.All {
+			continue
+		}
+		class := "asset"
+		if acct.Liability() {
+			class = "liability"
+		}
 		table.Append([]string{
-			a.Number,
-			a.Name,
-			strconv.FormatInt(a.Records, 10),
+			acct.Number,
+			acct.Name,
+			acct.Institution,
+			acct.Type,
+			class,
+			strconv.FormatInt(acct.Records, 10),
+			j.FormatAmount(acct.Balance),
 		})
 	}
 	table.Render()
 
+	unknown, err := j.UnknownAccounts()
+	if err != nil {
+		return err
+	}
+	for _, u := range unknown {
+		a.Log.Printf("warning: account %s is not declared in config", u.Number)
+	}
+	return nil
+}
+
+// Execute renames an account.
+func (r *RenameAccount) Execute(args []string) error {
+	j, err := journal.FromConfig(r.Config)
+	if err != nil {
+		return err
+	}
+	return j.RenameAccount(r.Args.Number, r.Args.Name)
+}
+
+// Execute removes an account.
+func (r *RemoveAccount) Execute(args []string) error {
+	j, err := journal.FromConfig(r.Config)
+	if err != nil {
+		return err
+	}
+	n, err := j.RemoveAccount(r.Args.Number, r.Purge)
+	if err != nil {
+		return err
+	}
+	r.Log.Printf("removed account %s and %d record(s)", r.Args.Number, n)
+	return nil
+}
+
+// Execute lists configured and stored groups.
+func (l *ListGroups) Execute(args []string) error {
+	j, err := journal.FromConfig(l.Config)
+	if err != nil {
+		return err
+	}
+	table := tablewriter.NewWriter(l.Writer)
+	table.SetHeader([]string{"Group", "Source", "Rules", "Pins"})
+	table.SetAutoWrapText(false)
+	table.SetColumnAlignment([]int{0, 0, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, g := range j.Groups() {
+		table.Append([]string{g.Name, g.Source(), strconv.Itoa(g.Rules), strconv.Itoa(g.Pins)})
+	}
+	table.Render()
+	return nil
+}
+
+// Execute stores a new group in the database.
+func (a *AddGroup) Execute(args []string) error {
+	j, err := journal.FromConfig(a.Config)
+	if err != nil {
+		return err
+	}
+	if err := j.AddGroup(a.Args.Name); err != nil {
+		return err
+	}
+	a.Log.Printf("added group %s", a.Args.Name)
+	return nil
+}
+
+// Execute removes a group from the database.
+func (r *RemoveGroup) Execute(args []string) error {
+	j, err := journal.FromConfig(r.Config)
+	if err != nil {
+		return err
+	}
+	n, err := j.RemoveGroup(r.Args.Name)
+	if err != nil {
+		return err
+	}
+	r.Log.Printf("removed %d rule(s) of group %s", n, r.Args.Name)
+	return nil
+}
+
+// Execute pins a record to a group.
+func (p *PinRecord) Execute(args []string) error {
+	j, err := journal.FromConfig(p.Config)
+	if err != nil {
+		return err
+	}
+	if err := j.PinRecord(p.Args.Group, p.Args.ID); err != nil {
+		return err
+	}
+	p.Log.Printf("pinned record %s to group %s", p.Args.ID, p.Args.Group)
+	return nil
+}
+
+// Execute removes pins of a record.
+func (u *UnpinRecord) Execute(args []string) error {
+	j, err := journal.FromConfig(u.Config)
+	if err != nil {
+		return err
+	}
+	n, err := j.UnpinRecord(u.Args.ID)
+	if err != nil {
+		return err
+	}
+	u.Log.Printf("removed %d pin(s) of record %s", n, u.Args.ID)
+	return nil
+}
+
+// Execute adds a pattern to a group.
+func (a *AddPattern) Execute(args []string) error {
+	j, err := journal.FromConfig(a.Config)
+	if err != nil {
+		return err
+	}
+	if err := j.AddGroupPattern(a.Args.Group, a.Args.Pattern); err != nil {
+		return err
+	}
+	a.Log.Printf("added pattern %q to group %s", a.Args.Pattern, a.Args.Group)
+	return nil
+}
+
+// Execute merges one account into another.
+func (m *MergeAccounts) Execute(args []string) error {
+	j, err := journal.FromConfig(m.Config)
+	if err != nil {
+		return err
+	}
+	n, err := j.MergeAccounts(m.Args.From, m.Args.To)
+	if err != nil {
+		return err
+	}
+	m.Log.Printf("moved %d record(s) from account %s to %s", n, m.Args.From, m.Args.To)
 	return nil
 }
 
@@ -141,6 +478,8 @@ func (l *List) Execute(args []string) error {
 	}
 
 	j.Discarding = !l.All
+	// Closed accounts are only shown when explicitly requested
+	j.Filter = journal.Filter{Type: l.Type, Closed: l.All || len(l.Args.Accounts) > 0, Transfers: l.All}
 	clock := newClock()
 	var s, u time.Time
 	if l.Month != 0 {
@@ -161,17 +500,45 @@ func (l *List) Execute(args []string) error {
 		return err
 	}
 
-	rs, err := j.Read(l.Args.Account, s, u)
+	accounts, err := j.ResolveAccounts(l.Args.Accounts, l.ExcludeAccounts)
+	if err != nil {
+		return err
+	}
+	rs, err := j.ReadAmortized(accounts, s, u)
 	if err != nil {
 		return err
 	}
+	rs = filterRecords(rs, j.Filter.Keep)
 
 	account := "all accounts"
-	if l.Args.Account != "" {
-		account = "account " + l.Args.Account
+	if len(accounts) == 1 {
+		account = "account " + accounts[0]
+	} else if len(accounts) > 1 {
+		account = "accounts " + strings.Join(accounts, ", ")
 	}
 	l.Log.Printf("displaying records for %s between %s and %s", account, s.Format(timeLayout), u.Format(timeLayout))
 
+	if j.Envelope() && l.Explain == "" {
+		history, err := j.ReadAmortized(accounts, time.Time{}, u)
+		if err != nil {
+			return err
+		}
+		report, err := j.Envelopes(filterRecords(history, j.Filter.Keep), s, u)
+		if err != nil {
+			return err
+		}
+		if n := report.ToBeBudgeted(); n < 0 {
+			l.Log.Printf("warning: allocations exceed income by %s", j.FormatAmount(-n))
+		}
+		envelopes := l.visibleEnvelopes(report.Envelopes, sortField)
+		if len(envelopes) == 0 {
+			l.Log.Printf("0 envelopes found")
+			return nil
+		}
+		l.printEnvelopes(envelopes, report.ToBeBudgeted(), j.FormatAmount)
+		return nil
+	}
+
 	rgs := j.Assort(rs)
 	if len(rgs) == 0 {
 		l.Log.Printf("0 records found")
@@ -179,13 +546,36 @@ func (l *List) Execute(args []string) error {
 	}
 
 	if l.Explain != "" {
-		l.printAll(rgs, l.Explain, j.FormatAmount, sortField)
+		l.printAll(rgs, l.Explain, j.FormatAmount, ruleOf(j), sortField)
 	} else {
-		l.printGroups(rgs, j.FormatAmount, sortField, record.Range{Since: s, Until: u})
+		rgs = j.Rollup(l.visibleGroups(rgs))
+		if err := j.Spend(rgs, accounts, u); err != nil {
+			return err
+		}
+		if err := j.Carry(rgs, accounts, s); err != nil {
+			return err
+		}
+		r := record.Range{Since: s, Until: u, Prorate: l.Prorate}
+		if l.Prorate {
+			// A range containing the current time is prorated by the days elapsed so far
+			r.Until = clock.elapsed(s, u)
+		}
+		columns := listColumns{carried: j.HasRollover(), period: j.HasBudgetPeriods(), pace: l.Prorate}
+		l.printGroups(rgs, j.FormatAmount, sortField, r, columns)
 	}
 	return nil
 }
 
+func filterRecords(rs []record.Record, keep func(record.Record) bool) []record.Record {
+	var filtered []record.Record
+	for _, r := range rs {
+		if keep(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
 func (l *List) sortField() (record.Field, error) {
 	switch l.OrderBy {
 	case "group":
@@ -203,24 +593,79 @@ func (l *List) sortField() (record.Field, error) {
 	return 0, fmt.Errorf("invalid sort field: %q", l.OrderBy)
 }
 
-func (l *List) printGroups(rgs []record.Group, fmtAmount func(int64) string, sortField record.Field, r record.Range) {
-	if len(l.HideGroups) > 0 && len(rgs) > 0 {
-		var filtered []record.Group
-	filter:
-		for _, rg := range rgs {
-			for _, hideGroup := range l.HideGroups {
-				if rg.Name == hideGroup {
-					continue filter
-				}
+func (l *List) flattenGroups(rgs []record.Group, sortField record.Field) []record.Group {
+	var flattened []record.Group
+	record.SortGroup(rgs, sortField)
+	for _, rg := range rgs {
+		flattened = append(flattened, rg)
+		if l.Depth == 0 || rg.Depth() < l.Depth {
+			flattened = append(flattened, l.flattenGroups(rg.Children, sortField)...)
+		}
+	}
+	return flattened
+}
+
+// listColumns are the optional columns of grouped output.
+type listColumns struct {
+	carried bool // Balance carried over from earlier months
+	period  bool // Budget utilisation in the current budget period
+	pace    bool // Projected sum at the end of the month
+}
+
+// utilisation returns the budget utilisation of group rg in the budget period containing t.
+func utilisation(rg record.Group, t time.Time) string {
+	u, ok := rg.Utilisation(t)
+	if !ok || rg.BudgetPeriod() == record.MonthPeriod {
+		return ""
+	}
+	return fmt.Sprintf("%d%% of %s", int64(math.Round(u*100)), rg.BudgetPeriod())
+}
+
+// pace returns the projected sum of group rg at the end of the month, flagging groups on track to exceed their budget.
+func pace(rg record.Group, r record.Range, fmtAmount func(int64) string, s *sgr) string {
+	projected := fmtAmount(rg.Pace(r))
+	if !rg.OverPace(r) {
+		return projected
+	}
+	c, d := s.color(1)
+	return c + projected + " (over)" + d
+}
+
+// visibleGroups returns the groups in rgs that are not hidden.
+func (l *List) visibleGroups(rgs []record.Group) []record.Group {
+	if len(l.HideGroups) == 0 {
+		return rgs
+	}
+	var filtered []record.Group
+filter:
+	for _, rg := range rgs {
+		for _, hideGroup := range l.HideGroups {
+			if record.InGroup(rg.Name, hideGroup) {
+				continue filter
 			}
-			filtered = append(filtered, rg)
 		}
-		rgs = filtered
+		filtered = append(filtered, rg)
 	}
+	return filtered
+}
+
+func (l *List) printGroups(rgs []record.Group, fmtAmount func(int64) string, sortField record.Field, r record.Range, columns listColumns) {
+	rows := l.flattenGroups(rgs, sortField)
 	table := tablewriter.NewWriter(l.Writer)
-	var rows [][]string
-	headers := []string{"Group", "Records", "Sum", "Budget", "Balance", "Balance bar"}
-	rows = append(rows, headers)
+	var cells [][]string
+	headers := []string{"Group", "Records", "Sum"}
+	if columns.carried {
+		headers = append(headers, "Carried")
+	}
+	headers = append(headers, "Budget", "Balance")
+	if columns.period {
+		headers = append(headers, "Period")
+	}
+	if columns.pace {
+		headers = append(headers, "Pace")
+	}
+	headers = append(headers, "Balance bar")
+	cells = append(cells, headers)
 	table.SetHeader(headers)
 	table.SetAutoWrapText(false)
 	alignments := make([]int, len(headers))
@@ -234,34 +679,46 @@ func (l *List) printGroups(rgs []record.Group, fmtAmount func(int64) string, sor
 		totalBalance int64
 		totalSum     int64
 		totalBudget  int64
+		totalCarried int64
 	)
 	s := sgr{
-		min:     record.MinBalance(rgs, r),
-		max:     record.MaxBalance(rgs, r),
+		min:     record.MinBalance(rows, r),
+		max:     record.MaxBalance(rows, r),
 		enabled: l.colorize(),
 	}
-	record.SortGroup(rgs, sortField)
-	for _, rg := range rgs {
+	for _, rg := range rows {
 		var (
 			records = len(rg.Records)
 			balance = rg.Balance(r)
 			sum     = rg.Sum()
 			budget  = rg.Budget(r)
+			carry   = rg.Carried()
 			c, d    = s.color(balance)
 		)
-		totalRecords += records
-		totalBalance += balance
-		totalSum += sum
-		totalBudget += budget
+		if rg.Depth() == 1 {
+			totalRecords += records
+			totalBalance += balance
+			totalSum += sum
+			totalBudget += budget
+			totalCarried += carry
+		}
 		row := []string{
-			rg.Name,
+			strings.Repeat("  ", rg.Depth()-1) + rg.BaseName(),
 			strconv.Itoa(records),
 			fmtAmount(sum),
-			fmtAmount(budget),
-			c + fmtAmount(balance) + d,
-			s.bar(balance),
 		}
-		rows = append(rows, row)
+		if columns.carried {
+			row = append(row, fmtAmount(carry))
+		}
+		row = append(row, fmtAmount(budget), c+fmtAmount(balance)+d)
+		if columns.period {
+			row = append(row, utilisation(rg, r.Until))
+		}
+		if columns.pace {
+			row = append(row, pace(rg, r, fmtAmount, &s))
+		}
+		row = append(row, s.bar(balance))
+		cells = append(cells, row)
 		table.Append(row)
 	}
 
@@ -274,66 +731,203 @@ func (l *List) printGroups(rgs []record.Group, fmtAmount func(int64) string, sor
 	footer.SetAutoWrapText(false)
 	footer.SetBorders(tablewriter.Border{Left: true, Right: true, Bottom: true})
 	for column := range headers {
-		footer.SetColMinWidth(column, maxLen(column, rows))
+		footer.SetColMinWidth(column, maxLen(column, cells))
 	}
 	c, d := s.color(totalBalance)
-	footer.Append([]string{
+	total := []string{
 		"Total",
 		strconv.Itoa(totalRecords),
 		fmtAmount(totalSum),
-		fmtAmount(totalBudget),
-		c + fmtAmount(totalBalance) + d,
-		s.bar(totalBalance),
-	})
+	}
+	if columns.carried {
+		total = append(total, fmtAmount(totalCarried))
+	}
+	total = append(total, fmtAmount(totalBudget), c+fmtAmount(totalBalance)+d)
+	if columns.period {
+		total = append(total, "")
+	}
+	if columns.pace {
+		total = append(total, "")
+	}
+	footer.Append(append(total, s.bar(totalBalance)))
 
 	table.Render()
 	footer.Render()
 }
 
-func (l *List) colorize() bool {
-	switch l.Color {
-	case "always":
-		return true
-	case "never":
-		return false
+// visibleEnvelopes returns the envelopes in es that are not hidden, with envelopes nested deeper than the depth option
+// merged into their ancestor, ordered by sortField.
+func (l *List) visibleEnvelopes(es []journal.Envelope, sortField record.Field) []journal.Envelope {
+	var visible []journal.Envelope
+	index := make(map[string]int)
+filter:
+	for _, e := range es {
+		for _, hideGroup := range l.HideGroups {
+			if record.InGroup(e.Group, hideGroup) {
+				continue filter
+			}
+		}
+		if l.Depth > 0 {
+			if parts := strings.Split(e.Group, record.Separator); len(parts) > l.Depth {
+				e.Group = strings.Join(parts[:l.Depth], record.Separator)
+			}
+		}
+		i, ok := index[e.Group]
+		if !ok {
+			index[e.Group] = len(visible)
+			visible = append(visible, e)
+			continue
+		}
+		v := &visible[i]
+		v.Records += e.Records
+		v.Allocated += e.Allocated
+		v.Spent += e.Spent
+		v.Carried += e.Carried
 	}
-	return !l.Options.IsPipe
+	sort.SliceStable(visible, func(i, k int) bool {
+		switch sortField {
+		case record.GroupField:
+			return visible[i].Group < visible[k].Group
+		case record.SumField:
+			return visible[i].Spent < visible[k].Spent
+		}
+		return false
+	})
+	return visible
 }
 
-func (l *List) printAll(rgs []record.Group, group string, fmtAmount func(int64) string, sortField record.Field) {
+func (l *List) printEnvelopes(envelopes []journal.Envelope, toBeBudgeted int64, fmtAmount func(int64) string) {
 	table := tablewriter.NewWriter(l.Writer)
-	table.SetHeader([]string{"Account", "Account name", "ID", "Date", "Group", "Text", "Amount"})
-	table.SetColumnAlignment([]int{
-		0, 0, 0, 0, 0, 0, tablewriter.ALIGN_RIGHT,
-	})
-	gs := make(map[string]string)
-	rs := []record.Record{}
-	for _, rg := range rgs {
-		for _, r := range rg.Records {
-			gs[r.ID()] = rg.Name
-			rs = append(rs, r)
-		}
+	headers := []string{"Group", "Records", "Carried", "Allocated", "Spent", "Available"}
+	cells := [][]string{headers}
+	table.SetHeader(headers)
+	table.SetAutoWrapText(false)
+	alignments := []int{tablewriter.ALIGN_DEFAULT}
+	for range headers[1:] {
+		alignments = append(alignments, tablewriter.ALIGN_RIGHT)
 	}
-	record.Sort(rs, sortField)
-	var sum int64
-	for _, r := range rs {
-		groupName := gs[r.ID()]
-		if group != "all" && group != groupName {
+	table.SetColumnAlignment(alignments)
+	s := sgr{enabled: l.colorize()}
+	var (
+		totalRecords   = 0
+		totalCarried   int64
+		totalAllocated int64
+		totalSpent     int64
+		totalAvailable int64
+	)
+	for _, e := range envelopes {
+		available := e.Available()
+		c, d := s.color(-available) // Overspent envelopes are colored as over budget
+		row := []string{
+			e.Group,
+			strconv.Itoa(e.Records),
+			fmtAmount(e.Carried),
+			fmtAmount(e.Allocated),
+			fmtAmount(e.Spent),
+			c + fmtAmount(available) + d,
+		}
+		cells = append(cells, row)
+		table.Append(row)
+		totalRecords += e.Records
+		totalCarried += e.Carried
+		totalAllocated += e.Allocated
+		totalSpent += e.Spent
+		totalAvailable += available
+	}
+
+	// See printGroups for why the footer is rendered as a separate table. Both tables are sized to fit the rows of
+	// the other.
+	c, d := s.color(-totalAvailable)
+	total := []string{
+		"Total",
+		strconv.Itoa(totalRecords),
+		fmtAmount(totalCarried),
+		fmtAmount(totalAllocated),
+		fmtAmount(totalSpent),
+		c + fmtAmount(totalAvailable) + d,
+	}
+	c, d = s.color(-toBeBudgeted)
+	unallocated := []string{"To be budgeted", "", "", "", "", c + fmtAmount(toBeBudgeted) + d}
+	cells = append(cells, total, unallocated)
+	footer := tablewriter.NewWriter(l.Writer)
+	footer.SetColumnAlignment(alignments)
+	footer.SetAutoWrapText(false)
+	footer.SetBorders(tablewriter.Border{Left: true, Right: true, Bottom: true})
+	for column := range headers {
+		table.SetColMinWidth(column, maxLen(column, cells))
+		footer.SetColMinWidth(column, maxLen(column, cells))
+	}
+	footer.Append(total)
+	footer.Append(unallocated)
+
+	table.Render()
+	footer.Render()
+}
+
+func (l *List) colorize() bool {
+	switch l.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return !l.Options.IsPipe
+}
+
+func ruleOf(j *journal.Journal) func(record.Record) string {
+	return func(r record.Record) string {
+		m, ok := j.MatchOf(r)
+		if !ok {
+			return ""
+		}
+		if m.Pinned {
+			return "pinned"
+		}
+		return m.Rule
+	}
+}
+
+func (l *List) printAll(rgs []record.Group, group string, fmtAmount func(int64) string, ruleFn func(record.Record) string, sortField record.Field) {
+	table := tablewriter.NewWriter(l.Writer)
+	table.SetHeader([]string{"Account", "Account name", "ID", "Date", "Group", "Rule", "Text", "Amount"})
+	table.SetColumnAlignment([]int{
+		0, 0, 0, 0, 0, 0, 0, tablewriter.ALIGN_RIGHT,
+	})
+	gs := make(map[string]string)
+	rs := []record.Record{}
+	for _, rg := range rgs {
+		for _, r := range rg.Records {
+			gs[r.ID()] = rg.Name
+			rs = append(rs, r)
+		}
+	}
+	record.Sort(rs, sortField)
+	var sum int64
+	for _, r := range rs {
+		groupName := gs[r.ID()]
+		if group != "all" && !record.InGroup(groupName, group) {
 			continue
 		}
 		sum += r.Amount
+		id, text := r.ID(), r.Text
+		if r.Slice != nil {
+			// Refer to the amortized record, as the slice itself is not stored
+			id = r.Slice.Original.ID()
+			text = fmt.Sprintf("%s (%d/%d)", r.Text, r.Slice.N, r.Slice.Of)
+		}
 		row := []string{
 			r.Account.Number,
 			r.Account.Name,
-			r.ID(),
+			id,
 			r.Time.Format("2006-01-02"),
 			groupName,
-			r.Text,
+			ruleFn(r),
+			text,
 			fmtAmount(r.Amount),
 		}
 		table.Append(row)
 	}
-	table.SetFooter([]string{"", "", "", "", "", "Total", fmtAmount(sum)})
+	table.SetFooter([]string{"", "", "", "", "", "", "Total", fmtAmount(sum)})
 	table.Render()
 }
 
@@ -350,13 +944,725 @@ func (e *Export) Execute(args []string) error {
 		return err
 	}
 
-	rs, err := j.Read(e.Args.Account, s, u)
+	accounts, err := j.ResolveAccounts(e.Args.Accounts, e.ExcludeAccounts)
+	if err != nil {
+		return err
+	}
+	rs, err := j.ReadAmortized(accounts, s, u)
 	if err != nil {
 		return err
 	}
+	j.Filter.Closed = true
+	rs = filterRecords(rs, j.Filter.Keep)
 
 	periods := j.AssortPeriod(rs, func(t time.Time) time.Time {
 		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
 	})
-	return j.Export(e.Writer, periods, "2006-01")
+	return j.Export(e.Writer, periods, "2006-01", e.Depth)
+}
+
+// Execute explains which group rules match a record.
+func (e *ExplainRule) Execute(args []string) error {
+	j, err := journal.FromConfig(e.Config)
+	if err != nil {
+		return err
+	}
+	r, found, err := j.FindRecord(e.Args.Record)
+	if err != nil {
+		return err
+	}
+	if found {
+		e.Log.Printf("explaining record %s: %q on %s in account %s", r.ID(), r.Text, r.Time.Format(timeLayout), r.Account.Number)
+	} else {
+		t, err := parseTime(e.Date)
+		if err != nil {
+			return err
+		}
+		if t.IsZero() {
+			now := newClock().now()
+			t = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		}
+		r = record.Record{Account: record.Account{Number: e.Account}, Time: t, Text: e.Args.Record, Amount: e.Amount}
+		e.Log.Printf("explaining text %q", r.Text)
+	}
+
+	table := tablewriter.NewWriter(e.Writer)
+	table.SetHeader([]string{"Group", "Kind", "Rule", "Result"})
+	table.SetAutoWrapText(false)
+	winner := ""
+	for _, m := range j.Explain(r) {
+		kind := "rule"
+		if m.Pinned {
+			kind = "pin"
+		} else if m.Classified {
+			kind = "classifier"
+		}
+		result := "no match"
+		if m.Matched {
+			if winner == "" {
+				winner = m.Group
+				result = "winner"
+				if m.Discard {
+					result += " (discard)"
+				}
+			} else {
+				result = "shadowed"
+			}
+		}
+		table.Append([]string{m.Group, kind, m.Rule, result})
+	}
+	table.Render()
+	if winner == "" {
+		e.Log.Printf("no rule matched, record belongs to %s", j.DefaultGroup)
+	}
+	return nil
+}
+
+// Execute reports problems in the group configuration.
+func (l *LintRules) Execute(args []string) error {
+	j, err := journal.FromConfig(l.Config)
+	if err != nil {
+		return err
+	}
+	problems, err := j.Lint(newClock().now())
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		l.Log.Printf("no problems found")
+		return nil
+	}
+	table := tablewriter.NewWriter(l.Writer)
+	table.SetHeader([]string{"Group", "Rule", "Problem"})
+	table.SetAutoWrapText(false)
+	for _, p := range problems {
+		table.Append([]string{p.Group, p.Rule, p.Message})
+	}
+	table.Render()
+	return fmt.Errorf("found %d problem(s)", len(problems))
+}
+
+// Execute reports all problems in the config file.
+func (c *CheckConfig) Execute(args []string) error {
+	problems, err := journal.CheckConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		c.Log.Printf("no problems found")
+		return nil
+	}
+	for _, p := range problems {
+		fmt.Fprintln(c.Writer, p.Error())
+	}
+	return fmt.Errorf("found %d problem(s)", len(problems))
+}
+
+// Execute suggests group rules for ungrouped records.
+func (s *SuggestRules) Execute(args []string) error {
+	j, err := journal.FromConfig(s.Config)
+	if err != nil {
+		return err
+	}
+	since, err := parseTime(s.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(s.Until)
+	if err != nil {
+		return err
+	}
+	rs, err := j.Read(nil, since, until)
+	if err != nil {
+		return err
+	}
+	suggestions := j.Suggest(rs, s.MinCount)
+	if len(suggestions) == 0 {
+		s.Log.Printf("no suggestions found")
+		return nil
+	}
+	if s.Limit > 0 && len(suggestions) > s.Limit {
+		suggestions = suggestions[:s.Limit]
+	}
+	if s.TOML {
+		for i, sg := range suggestions {
+			if i > 0 {
+				fmt.Fprintln(s.Writer)
+			}
+			fmt.Fprintf(s.Writer, "# %d record(s), sum %s, e.g. %q\n", len(sg.Records), j.FormatAmount(sg.Sum()), sg.Records[0].Text)
+			if sg.Group != "" {
+				fmt.Fprintf(s.Writer, "# Similar to existing group %q\n", sg.Group)
+			}
+			fmt.Fprintf(s.Writer, "[[groups]]\nname = %q\npatterns = [%q]\n", sg.Name, sg.Pattern)
+		}
+		return nil
+	}
+	table := tablewriter.NewWriter(s.Writer)
+	table.SetHeader([]string{"Records", "Sum", "Name", "Pattern", "Similar group", "Example"})
+	table.SetAutoWrapText(false)
+	table.SetColumnAlignment([]int{
+		tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, 0, 0, 0, 0,
+	})
+	for _, sg := range suggestions {
+		table.Append([]string{
+			strconv.Itoa(len(sg.Records)),
+			j.FormatAmount(sg.Sum()),
+			sg.Name,
+			sg.Pattern,
+			sg.Group,
+			sg.Records[0].Text,
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// Execute proposes groups for records that are not matched by any rule.
+func (c *Classify) Execute(args []string) error {
+	j, err := journal.FromConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	since, err := parseTime(c.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(c.Until)
+	if err != nil {
+		return err
+	}
+	rs, err := j.Read(nil, since, until)
+	if err != nil {
+		return err
+	}
+	cs, err := j.Classify(rs)
+	if err != nil {
+		return err
+	}
+	table := tablewriter.NewWriter(c.Writer)
+	table.SetHeader([]string{"ID", "Date", "Text", "Amount", "Group", "Confidence"})
+	table.SetAutoWrapText(false)
+	table.SetColumnAlignment([]int{
+		0, 0, 0, tablewriter.ALIGN_RIGHT, 0, tablewriter.ALIGN_RIGHT,
+	})
+	n := 0
+	for _, cl := range cs {
+		if cl.Confidence < c.Min {
+			continue
+		}
+		table.Append([]string{
+			cl.Record.ID(),
+			cl.Record.Time.Format("2006-01-02"),
+			cl.Record.Text,
+			j.FormatAmount(cl.Record.Amount),
+			cl.Group,
+			fmt.Sprintf("%.0f%%", cl.Confidence*100),
+		})
+		n++
+	}
+	if n == 0 {
+		c.Log.Printf("no records to classify")
+		return nil
+	}
+	table.Render()
+	return nil
+}
+
+func (c *Categorize) prompt(input *bufio.Scanner, format string, v ...interface{}) (string, bool) {
+	fmt.Fprintf(c.Writer, format, v...)
+	if !input.Scan() {
+		fmt.Fprintln(c.Writer)
+		return "", false
+	}
+	return strings.TrimSpace(input.Text()), true
+}
+
+// chooseGroup prompts for a group, either by its number in groups or by name.
+func (c *Categorize) chooseGroup(input *bufio.Scanner, groups []string) (string, bool) {
+	for {
+		answer, ok := c.prompt(input, "Group number or name: ")
+		if !ok || answer == "" {
+			return "", false
+		}
+		if n, err := strconv.Atoi(answer); err == nil {
+			if n < 1 || n > len(groups) {
+				fmt.Fprintf(c.Writer, "invalid group number: %d\n", n)
+				continue
+			}
+			return groups[n-1], true
+		}
+		return answer, true
+	}
+}
+
+// Execute steps through ungrouped records and writes the chosen pins and patterns back to the config file.
+func (c *Categorize) Execute(args []string) error {
+	j, err := journal.FromConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	conf, err := journal.ReadConfigFile(c.Config)
+	if err != nil {
+		return err
+	}
+	since, err := parseTime(c.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(c.Until)
+	if err != nil {
+		return err
+	}
+	rs, err := j.Read(nil, since, until)
+	if err != nil {
+		return err
+	}
+	var ungrouped []record.Record
+	for _, r := range rs {
+		if _, ok := j.MatchOf(r); !ok {
+			ungrouped = append(ungrouped, r)
+		}
+	}
+	if len(ungrouped) == 0 {
+		c.Log.Printf("no ungrouped records found")
+		return nil
+	}
+	record.Sort(ungrouped, record.TimeField)
+
+	groups := j.GroupNames()
+	addGroup := func(name string) {
+		for _, g := range groups {
+			if g == name {
+				return
+			}
+		}
+		groups = append(groups, name)
+	}
+	var patterns []*regexp.Regexp
+	input := bufio.NewScanner(c.Input)
+	changes := 0
+	quit := false
+	for i, r := range ungrouped {
+		if quit {
+			break
+		}
+		matched := false
+		for _, p := range patterns {
+			if p.MatchString(r.Text) {
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		fmt.Fprintf(c.Writer, "\n[%d/%d] %s %s %q %s\n", i+1, len(ungrouped), r.ID(), r.Time.Format("2006-01-02"), r.Text, j.FormatAmount(r.Amount))
+		for n, g := range groups {
+			fmt.Fprintf(c.Writer, "  %d) %s\n", n+1, g)
+		}
+		for {
+			answer, ok := c.prompt(input, "Pin to group number, (n)ew group, (p)attern, (s)kip or (q)uit [s]: ")
+			if !ok || answer == "q" {
+				quit = true
+				break
+			}
+			if answer == "" || answer == "s" {
+				break
+			}
+			var group string
+			if answer == "p" {
+				suggested := journal.SuggestPattern(r)
+				pattern, ok := c.prompt(input, "Pattern [%s]: ", suggested)
+				if !ok {
+					continue
+				}
+				if pattern == "" {
+					pattern = suggested
+				}
+				p, err := regexp.Compile(pattern)
+				if err != nil {
+					fmt.Fprintf(c.Writer, "invalid pattern: %s\n", err)
+					continue
+				}
+				if group, ok = c.chooseGroup(input, groups); !ok {
+					continue
+				}
+				if err := conf.AddPattern(group, pattern); err != nil {
+					return err
+				}
+				patterns = append(patterns, p)
+				fmt.Fprintf(c.Writer, "added pattern %q to %s\n", pattern, group)
+			} else {
+				if answer == "n" {
+					if group, ok = c.prompt(input, "New group name: "); !ok || group == "" {
+						continue
+					}
+				} else {
+					n, err := strconv.Atoi(answer)
+					if err != nil || n < 1 || n > len(groups) {
+						fmt.Fprintf(c.Writer, "invalid choice: %q\n", answer)
+						continue
+					}
+					group = groups[n-1]
+				}
+				if err := conf.Pin(group, r.ID()); err != nil {
+					return err
+				}
+				fmt.Fprintf(c.Writer, "pinned %s to %s\n", r.ID(), group)
+			}
+			addGroup(group)
+			changes++
+			break
+		}
+	}
+	if changes == 0 {
+		c.Log.Printf("no changes made")
+		return nil
+	}
+	if err := conf.Save(); err != nil {
+		return err
+	}
+	c.Log.Printf("saved %d change(s) to config", changes)
+	return nil
+}
+
+// Execute allocates an amount to a group in a month.
+func (a *Allocate) Execute(args []string) error {
+	j, err := journal.FromConfig(a.Config)
+	if err != nil {
+		return err
+	}
+	if !j.Envelope() {
+		return fmt.Errorf("envelope budgeting is not enabled in config")
+	}
+	month, err := time.Parse("2006-01", a.Args.Month)
+	if err != nil {
+		return fmt.Errorf("invalid month: %q", a.Args.Month)
+	}
+	if err := j.Allocate(month, a.Args.Group, a.Args.Amount); err != nil {
+		return err
+	}
+	if a.Args.Amount == 0 {
+		a.Log.Printf("removed allocation to %s in %s", a.Args.Group, month.Format("2006-01"))
+	} else {
+		a.Log.Printf("allocated %s to %s in %s", j.FormatAmount(a.Args.Amount), a.Args.Group, month.Format("2006-01"))
+	}
+	return nil
+}
+
+// Execute displays the progress of savings goals.
+func (g *Goals) Execute(args []string) error {
+	j, err := journal.FromConfig(g.Config)
+	if err != nil {
+		return err
+	}
+	now, err := parseTime(g.Date)
+	if err != nil {
+		return err
+	}
+	if now.IsZero() {
+		t := newClock().now()
+		now = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	ps, err := j.Goals(now)
+	if err != nil {
+		return err
+	}
+	if len(ps) == 0 {
+		g.Log.Printf("no goals found in config")
+		return nil
+	}
+	table := tablewriter.NewWriter(g.Writer)
+	table.SetHeader([]string{"Goal", "Target", "Date", "Saved", "Progress", "Remaining", "Monthly", "Status"})
+	table.SetAutoWrapText(false)
+	table.SetColumnAlignment([]int{
+		0, tablewriter.ALIGN_RIGHT, 0, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT,
+		tablewriter.ALIGN_RIGHT, 0,
+	})
+	for _, p := range ps {
+		table.Append([]string{
+			p.Name,
+			j.FormatAmount(p.Target),
+			p.Date.Format(timeLayout),
+			j.FormatAmount(p.Saved),
+			fmt.Sprintf("%d%%", p.Saved*100/p.Target),
+			j.FormatAmount(p.Remaining()),
+			j.FormatAmount(p.Monthly()),
+			p.Status(),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// Execute reports groups that are close to or over their budget in the current budget period.
+func (a *Alert) Execute(args []string) error {
+	j, err := journal.FromConfig(a.Config)
+	if err != nil {
+		return err
+	}
+	now, err := parseTime(a.Date)
+	if err != nil {
+		return err
+	}
+	if now.IsZero() {
+		t := newClock().now()
+		now = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	alerts, err := j.Alerts(now)
+	if err != nil {
+		return err
+	}
+	var anomalies []journal.Anomaly
+	if a.Anomalies {
+		since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if anomalies, err = j.Anomalies(since, now, 0, 0); err != nil {
+			return err
+		}
+	}
+	if len(alerts) == 0 && len(anomalies) == 0 {
+		a.Log.Printf("all groups are within budget")
+		return nil
+	}
+	over := 0
+	for _, alert := range alerts {
+		status := "warning"
+		if alert.Over() {
+			status = "over"
+			over++
+		}
+		fmt.Fprintf(a.Writer, "%s: %s: %s of %s (%.0f%%) since %s\n", status, alert.Group, j.FormatAmount(alert.Sum),
+			j.FormatAmount(alert.Budget), alert.Used*100, alert.Period.Since.Format(timeLayout))
+	}
+	for _, an := range anomalies {
+		name := an.Group
+		if an.Merchant != "" {
+			name += ": " + an.Merchant
+		}
+		when := "on " + an.Time.Format(timeLayout)
+		if an.Kind == journal.MonthAnomaly {
+			when = "in " + an.Time.Format("2006-01")
+		}
+		fmt.Fprintf(a.Writer, "anomaly: %s: %s %s %s, usually %s\n", name, an.Kind, j.FormatAmount(an.Amount), when,
+			j.FormatAmount(an.Median))
+	}
+	if !a.Quiet {
+		if err := j.Notify(alerts, anomalies, now); err != nil {
+			return err
+		}
+	}
+	switch {
+	case over > 0 && len(anomalies) > 0:
+		return fmt.Errorf("%d group(s) over budget, found %d anomalies", over, len(anomalies))
+	case over > 0:
+		return fmt.Errorf("%d group(s) over budget", over)
+	case len(anomalies) > 0:
+		return fmt.Errorf("found %d anomalies", len(anomalies))
+	}
+	return nil
+}
+
+// Execute detects and lists transfers between accounts.
+func (t *Transfers) Execute(args []string) error {
+	j, err := journal.FromConfig(t.Config)
+	if err != nil {
+		return err
+	}
+	since, err := parseTime(t.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(t.Until)
+	if err != nil {
+		return err
+	}
+	n, err := j.DetectTransfers()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		t.Log.Printf("detected %d new transfer(s)", n)
+	}
+	transfers, err := j.Transfers(since, until)
+	if err != nil {
+		return err
+	}
+	if len(transfers) == 0 {
+		t.Log.Printf("0 transfers found")
+		return nil
+	}
+	table := tablewriter.NewWriter(t.Writer)
+	table.SetHeader([]string{"Date", "From", "To", "Amount", "Text"})
+	table.SetAutoWrapText(false)
+	table.SetColumnAlignment([]int{0, 0, 0, tablewriter.ALIGN_RIGHT, 0})
+	for _, tr := range transfers {
+		table.Append([]string{
+			tr.From.Time.Format(timeLayout),
+			tr.From.Account.Name,
+			tr.To.Account.Name,
+			j.FormatAmount(tr.To.Amount),
+			tr.From.Text,
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// Execute lists recurring payments.
+func (r *Recurring) Execute(args []string) error {
+	j, err := journal.FromConfig(r.Config)
+	if err != nil {
+		return err
+	}
+	since, err := parseTime(r.Since)
+	if err != nil {
+		return err
+	}
+	until, err := parseTime(r.Until)
+	if err != nil {
+		return err
+	}
+	rs, err := j.Read(nil, since, until)
+	if err != nil {
+		return err
+	}
+	recurring := j.Recurring(rs, r.MinCount)
+	if len(recurring) == 0 {
+		r.Log.Printf("no recurring payments found")
+		return nil
+	}
+	table := tablewriter.NewWriter(r.Writer)
+	table.SetHeader([]string{"Name", "Cadence", "Amount", "Charges", "Last", "Next", "Price changes"})
+	table.SetAutoWrapText(false)
+	table.SetColumnAlignment([]int{0, 0, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, 0, 0, 0})
+	for _, rec := range recurring {
+		var changes []string
+		for _, c := range rec.Changes {
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", c.Time.Format(timeLayout), j.FormatAmount(c.From), j.FormatAmount(c.To)))
+		}
+		table.Append([]string{
+			rec.Name,
+			rec.Cadence,
+			j.FormatAmount(rec.Amount),
+			strconv.Itoa(len(rec.Records)),
+			rec.Last().Format(timeLayout),
+			rec.Next().Format(timeLayout),
+			strings.Join(changes, ", "),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+// Execute projects the balance of accounts.
+func (f *Forecast) Execute(args []string) error {
+	j, err := journal.FromConfig(f.Config)
+	if err != nil {
+		return err
+	}
+	if f.Months < 0 {
+		return fmt.Errorf("invalid number of months: %d", f.Months)
+	}
+	now, err := parseTime(f.Date)
+	if err != nil {
+		return err
+	}
+	if now.IsZero() {
+		now = newClock().now()
+	}
+	fs, err := j.Forecast(now, f.Months)
+	if err != nil {
+		return err
+	}
+	if len(fs) == 0 {
+		f.Log.Printf("no open accounts found in config")
+		return nil
+	}
+	for _, af := range fs {
+		if t, balance, ok := af.BelowFloor(); ok {
+			f.Log.Printf("warning: %s is projected to fall below %s on %s (%s)", af.Account.Name, j.FormatAmount(af.Floor),
+				t.Format(timeLayout), j.FormatAmount(balance))
+		}
+	}
+	header := []string{"Date"}
+	for _, af := range fs {
+		header = append(header, af.Account.Name)
+	}
+	var rows [][]string
+	for i := range fs[0].Balances {
+		t := fs[0].Time(i)
+		last := i == len(fs[0].Balances)-1
+		if !f.Daily && i > 0 && !last && t.AddDate(0, 0, 1).Day() != 1 {
+			continue // Only the first day and the last day of each month
+		}
+		row := []string{t.Format(timeLayout)}
+		for _, af := range fs {
+			row = append(row, j.FormatAmount(af.Balances[i]))
+		}
+		rows = append(rows, row)
+	}
+	if f.CSV {
+		w := csv.NewWriter(f.Writer)
+		if err := w.Write(header); err != nil {
+			return err
+		}
+		if err := w.WriteAll(rows); err != nil {
+			return err
+		}
+		return w.Error()
+	}
+	table := tablewriter.NewWriter(f.Writer)
+	table.SetHeader(header)
+	table.SetAutoWrapText(false)
+	alignment := []int{0}
+	for range fs {
+		alignment = append(alignment, tablewriter.ALIGN_RIGHT)
+	}
+	table.SetColumnAlignment(alignment)
+	table.AppendBulk(rows)
+	table.Render()
+	return nil
+}
+
+// Execute reports unusual records and monthly sums.
+func (a *Anomalies) Execute(args []string) error {
+	j, err := journal.FromConfig(a.Config)
+	if err != nil {
+		return err
+	}
+	j.Discarding = !a.All
+	// Records in closed accounts are still part of the history
+	j.Filter = journal.Filter{Closed: true, Transfers: a.All}
+	if a.Months < 1 || a.Threshold <= 0 {
+		return fmt.Errorf("months and threshold must be positive")
+	}
+	since, until, err := newClock().timeRange(a.Since, a.Until)
+	if err != nil {
+		return err
+	}
+	anomalies, err := j.Anomalies(since, until, a.Months, a.Threshold)
+	if err != nil {
+		return err
+	}
+	if len(anomalies) == 0 {
+		a.Log.Printf("no anomalies found")
+		return nil
+	}
+	table := tablewriter.NewWriter(a.Writer)
+	table.SetHeader([]string{"Date", "Kind", "Group", "Merchant", "Amount", "Usual", "Score"})
+	table.SetAutoWrapText(false)
+	table.SetColumnAlignment([]int{0, 0, 0, 0, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_RIGHT})
+	for _, an := range anomalies {
+		date := an.Time.Format(timeLayout)
+		if an.Kind == journal.MonthAnomaly {
+			date = an.Time.Format("2006-01")
+		}
+		score := ""
+		if an.Kind != journal.DuplicateAnomaly {
+			score = fmt.Sprintf("%.1f", an.Score)
+		}
+		table.Append([]string{date, an.Kind, an.Group, an.Merchant, j.FormatAmount(an.Amount), j.FormatAmount(an.Median), score})
+	}
+	table.Render()
+	return fmt.Errorf("found %d anomalies", len(anomalies))
 }
@@ -7,6 +7,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -157,15 +158,15 @@ func TestListExplain(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := `+---------------+--------------+------------+------------+-------+---------------+---------+
-|    ACCOUNT    | ACCOUNT NAME |     ID     |    DATE    | GROUP |     TEXT      | AMOUNT  |
-+---------------+--------------+------------+------------+-------+---------------+---------+
-| 1234.56.78900 | My account 1 | 66e7fcce66 | 2017-03-10 | B     | Transaction 2 |  -42.00 |
-| 1234.56.78900 | My account 1 | 11485ce462 | 2017-04-20 | B     | Transaction 3 |   42.00 |
-| 1234.56.78900 | My account 1 | ed5c019f5d | 2017-02-01 | A     | Transaction 1 | 1337.00 |
-+---------------+--------------+------------+------------+-------+---------------+---------+
-|                                                                      TOTAL     | 1337.00 |
-+---------------+--------------+------------+------------+-------+---------------+---------+
+	want := `+---------------+--------------+------------+------------+-------+-------------------+---------------+---------+
+|    ACCOUNT    | ACCOUNT NAME |     ID     |    DATE    | GROUP |       RULE        |     TEXT      | AMOUNT  |
++---------------+--------------+------------+------------+-------+-------------------+---------------+---------+
+| 1234.56.78900 | My account 1 | 66e7fcce66 | 2017-03-10 | B     | Transaction [2-3] | Transaction 2 |  -42.00 |
+| 1234.56.78900 | My account 1 | 11485ce462 | 2017-04-20 | B     | Transaction [2-3] | Transaction 3 |   42.00 |
+| 1234.56.78900 | My account 1 | ed5c019f5d | 2017-02-01 | A     | Transaction 1     | Transaction 1 | 1337.00 |
++---------------+--------------+------------+------------+-------+-------------------+---------------+---------+
+|                                                                                          TOTAL     | 1337.00 |
++---------------+--------------+------------+------------+-------+-------------------+---------------+---------+
 `
 	testString(t, stdout.String(), want)
 }
@@ -238,11 +239,972 @@ func TestAccounts(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	want := `+---------------+--------------+---------+
-|    NUMBER     |     NAME     | RECORDS |
-+---------------+--------------+---------+
-| 1234.56.78900 | My account 1 |       3 |
-+---------------+--------------+---------+
+	want := `+---------------+--------------+-------------+------+-------+---------+---------+
+|    NUMBER     |     NAME     | INSTITUTION | TYPE | CLASS | RECORDS | BALANCE |
++---------------+--------------+-------------+------+-------+---------+---------+
+| 1234.56.78900 | My account 1 |             |      | asset |       3 | 1337.00 |
++---------------+--------------+-------------+------+-------+---------+---------+
 `
 	testString(t, stdout.String(), want)
 }
+
+func TestRemoveAccount(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	rm := RemoveAccount{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+	}
+	rm.Args.Number = "1234.56.78900"
+	want := "account 1234.56.78900 has 3 record(s)"
+	if err := rm.Execute(nil); err == nil || err.Error() != want {
+		t.Errorf("want error %q, got %q", want, err)
+	}
+
+	rm.Purge = true
+	if err := rm.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	testString(t, stderr.String(), "journal: removed account 1234.56.78900 and 3 record(s)\n")
+}
+
+const hierarchyConf = `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "All"
+budget = 100000
+
+[[groups]]
+name = "All/A"
+budget = 50000
+patterns = ["Transaction 1"]
+
+[[groups]]
+name = "B"
+parent = "All"
+patterns = ["Transaction [2-3]"]
+`
+
+func TestListHierarchy(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, hierarchyConf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	ls := List{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr), Color: "never"},
+		Since:   "2017-02-01",
+		Until:   "2017-04-30",
+	}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+-------+---------+---------+---------+---------+--------------------------------+
+| GROUP | RECORDS |   SUM   | BUDGET  | BALANCE |          BALANCE BAR           |
++-------+---------+---------+---------+---------+--------------------------------+
+| All   |       3 | 1337.00 | 3000.00 | 1663.00 |                 ++++++++++++++ |
+|   B   |       2 |    0.00 |    0.00 |    0.00 |                                |
+|   A   |       1 | 1337.00 | 1500.00 |  163.00 |                 ++             |
++-------+---------+---------+---------+---------+--------------------------------+
+| Total |       3 | 1337.00 | 3000.00 | 1663.00 |                 ++++++++++++++ |
++-------+---------+---------+---------+---------+--------------------------------+
+`
+	testString(t, stdout.String(), want)
+
+	stdout.Reset()
+	ls.Depth = 1
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want = `+-------+---------+---------+---------+---------+--------------------------------+
+| GROUP | RECORDS |   SUM   | BUDGET  | BALANCE |          BALANCE BAR           |
++-------+---------+---------+---------+---------+--------------------------------+
+| All   |       3 | 1337.00 | 3000.00 | 1663.00 |                                |
++-------+---------+---------+---------+---------+--------------------------------+
+| Total |       3 | 1337.00 | 3000.00 | 1663.00 |                                |
++-------+---------+---------+---------+---------+--------------------------------+
+`
+	testString(t, stdout.String(), want)
+
+	stdout.Reset()
+	export := Export{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+		Since:   "2017-01-01",
+		Depth:   1,
+	}
+	if err := export.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want = `2017-04,All,42.00
+2017-03,All,-42.00
+2017-02,All,1337.00
+`
+	testString(t, stdout.String(), want)
+
+	stdout.Reset()
+	export.Depth = 0
+	if err := export.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want = `2017-04,All,42.00
+2017-04,All/B,42.00
+2017-03,All,-42.00
+2017-03,All/B,-42.00
+2017-02,All,1337.00
+2017-02,All/A,1337.00
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestExplainRule(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	explain := ExplainRule{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+	}
+	explain.Args.Record = "66e7fcce66"
+	if err := explain.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+-------+------+-------------------+----------+
+| GROUP | KIND |       RULE        |  RESULT  |
++-------+------+-------------------+----------+
+| A     | rule | Transaction 1     | no match |
+| B     | rule | Transaction [2-3] | winner   |
++-------+------+-------------------+----------+
+`
+	testString(t, stdout.String(), want)
+	testString(t, stderr.String(), "journal: explaining record 66e7fcce66: \"Transaction 2\" on 2017-03-10 in account 1234.56.78900\n")
+
+	stdout.Reset()
+	stderr.Reset()
+	explain.Args.Record = "Transaction 4"
+	if err := explain.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	testString(t, stderr.String(), "journal: explaining text \"Transaction 4\"\njournal: no rule matched, record belongs to * ungrouped *\n")
+}
+
+func TestLintRules(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	lint := LintRules{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+	}
+	if err := lint.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	testString(t, stderr.String(), "journal: no problems found\n")
+	testString(t, stdout.String(), "")
+}
+
+func TestSuggestRules(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A"
+patterns = ["Transaction 1"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	suggest := SuggestRules{
+		Options:  Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+		MinCount: 2,
+		TOML:     true,
+	}
+	if err := suggest.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `# 2 record(s), sum 0.00, e.g. "Transaction 3"
+# Similar to existing group "A"
+[[groups]]
+name = "Transaction"
+patterns = ["(?i)^transaction \\d+"]
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestClassify(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A"
+patterns = ["Transaction [12]"]
+
+[[groups]]
+name = "B"
+patterns = ["Other"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	classify := Classify{Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)}}
+	if err := classify.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+------------+------------+---------------+--------+-------+------------+
+|     ID     |    DATE    |     TEXT      | AMOUNT | GROUP | CONFIDENCE |
++------------+------------+---------------+--------+-------+------------+
+| 11485ce462 | 2017-04-20 | Transaction 3 |  42.00 | A     |       100% |
++------------+------------+---------------+--------+-------+------------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestCategorize(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `# Test config
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A" # First group
+patterns = ["Transaction 1"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	categorize := Categorize{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+		Input:   strings.NewReader("x\n1\np\n\nB\n"),
+	}
+	if err := categorize.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `
+[1/2] 66e7fcce66 2017-03-10 "Transaction 2" -42.00
+  1) A
+Pin to group number, (n)ew group, (p)attern, (s)kip or (q)uit [s]: invalid choice: "x"
+Pin to group number, (n)ew group, (p)attern, (s)kip or (q)uit [s]: pinned 66e7fcce66 to A
+
+[2/2] 11485ce462 2017-04-20 "Transaction 3" 42.00
+  1) A
+Pin to group number, (n)ew group, (p)attern, (s)kip or (q)uit [s]: Pattern [(?i)^transaction 3]: Group number or name: added pattern "(?i)^transaction 3" to B
+`
+	testString(t, stdout.String(), want)
+
+	data, err := ioutil.ReadFile(f.conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = fmt.Sprintf(`# Test config
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A" # First group
+ids = ["66e7fcce66"]
+patterns = ["Transaction 1"]
+
+[[groups]]
+name = "B"
+patterns = ["(?i)^transaction 3"]
+`, f.db)
+	testString(t, string(data), want)
+}
+
+func TestGroups(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	opts := Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)}
+	add := AddGroup{Options: opts}
+	add.Args.Name = "C"
+	if err := add.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	pattern := AddPattern{Options: opts}
+	pattern.Args.Group = "C"
+	pattern.Args.Pattern = "Transaction 3"
+	if err := pattern.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	pin := PinRecord{Options: opts}
+	pin.Args.Group = "C"
+	pin.Args.ID = "66e7fcce66"
+	if err := pin.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	ls := ListGroups{Options: opts}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+-------+----------+-------+------+
+| GROUP |  SOURCE  | RULES | PINS |
++-------+----------+-------+------+
+| A     | config   |     1 |    0 |
+| B     | config   |     1 |    0 |
+| C     | database |     1 |    1 |
++-------+----------+-------+------+
+`
+	testString(t, stdout.String(), want)
+
+	stdout.Reset()
+	list := List{Options: opts, Explain: "C", Since: "2017-01-01"}
+	if err := list.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := stdout.String(); !strings.Contains(got, "Transaction 2") || strings.Contains(got, "Transaction 3") {
+		t.Errorf("want pinned record in group C and config rule to take precedence, got\n%s", got)
+	}
+
+	rm := RemoveGroup{Options: opts}
+	rm.Args.Name = "A"
+	if err := rm.Execute(nil); err == nil {
+		t.Error("want error when removing group defined in config")
+	}
+	rm.Args.Name = "C"
+	if err := rm.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "journal: removed 3 rule(s) of group C\n"; !strings.HasSuffix(stderr.String(), want) {
+		t.Errorf("want log ending with %q, got %q", want, stderr.String())
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+
+	var stdout, stderr bytes.Buffer
+	check := CheckConfig{Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)}}
+	if err := check.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	testString(t, stderr.String(), "journal: no problems found\n")
+
+	conf := `
+Database = "%s"
+
+[[groups]]
+name = "A"
+pattern = ["Transaction 1"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := check.Execute(nil); err == nil {
+		t.Error("want error")
+	}
+	testString(t, stdout.String(), f.conf+`:6: unknown key: "groups.pattern", did you mean "patterns"?`+"\n")
+}
+
+func TestListRollover(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A"
+patterns = ["Transaction 1"]
+
+[[groups]]
+name = "B"
+budget = -5000
+rollover = true
+
+[[groups]]
+name = "C"
+parent = "B"
+patterns = ["Transaction [2-3]"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	ls := List{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr), Color: "never"},
+		Since:   "2017-04-01",
+		Until:   "2017-04-30",
+	}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+-------+---------+-------+---------+--------+---------+--------------------------------+
+| GROUP | RECORDS |  SUM  | CARRIED | BUDGET | BALANCE |          BALANCE BAR           |
++-------+---------+-------+---------+--------+---------+--------------------------------+
+| B     |       1 | 42.00 |   -8.00 | -50.00 | -100.00 | ----------------               |
+|   C   |       1 | 42.00 |    0.00 |   0.00 |  -42.00 |    -------------               |
++-------+---------+-------+---------+--------+---------+--------------------------------+
+| Total |       1 | 42.00 |   -8.00 | -50.00 | -100.00 | ----------------               |
++-------+---------+-------+---------+--------+---------+--------------------------------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestListBudgetPeriod(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A"
+patterns = ["Transaction 1"]
+
+[[groups]]
+name = "B"
+patterns = ["Transaction 3"]
+
+[[groups]]
+name = "C"
+patterns = ["Transaction 2"]
+budget = -4200
+budgetPeriod = "year"
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	ls := List{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr), Color: "never"},
+		Since:   "2017-03-01",
+		Until:   "2017-03-31",
+	}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+-------+---------+--------+--------+---------+--------------+--------------------------------+
+| GROUP | RECORDS |  SUM   | BUDGET | BALANCE |    PERIOD    |          BALANCE BAR           |
++-------+---------+--------+--------+---------+--------------+--------------------------------+
+| C     |       1 | -42.00 |  -3.56 |   38.44 | 100% of year |                                |
++-------+---------+--------+--------+---------+--------------+--------------------------------+
+| Total |       1 | -42.00 |  -3.56 |   38.44 |              |                                |
++-------+---------+--------+--------+---------+--------------+--------------------------------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestListProrate(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A"
+patterns = ["Transaction 1"]
+
+[[groups]]
+name = "B"
+patterns = ["Transaction 2"]
+budget = -6200
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	ls := List{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr), Color: "never"},
+		Since:   "2017-03-01",
+		Until:   "2017-03-10",
+		Prorate: true,
+	}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+-------+---------+--------+--------+---------+----------------+--------------------------------+
+| GROUP | RECORDS |  SUM   | BUDGET | BALANCE |      PACE      |          BALANCE BAR           |
++-------+---------+--------+--------+---------+----------------+--------------------------------+
+| B     |       1 | -42.00 | -20.00 |   22.00 | -130.20 (over) |                                |
++-------+---------+--------+--------+---------+----------------+--------------------------------+
+| Total |       1 | -42.00 | -20.00 |   22.00 |                |                                |
++-------+---------+--------+--------+---------+----------------+--------------------------------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestEnvelope(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+envelope = true
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "Salary"
+patterns = ["Transaction 1"]
+income = true
+
+[[groups]]
+name = "Shopping"
+patterns = ["Transaction [2-3]"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stderr bytes.Buffer
+	opts := Options{Config: f.conf, Writer: ioutil.Discard, Log: NewLogger(&stderr), Color: "never"}
+	allocations := []struct {
+		month  string
+		amount int64
+	}{{"2017-03", 100000}, {"2017-04", 50000}}
+	for _, a := range allocations {
+		allocate := Allocate{Options: opts}
+		allocate.Args.Month, allocate.Args.Group, allocate.Args.Amount = a.month, "Shopping", a.amount
+		if err := allocate.Execute(nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	allocate := Allocate{Options: opts}
+	allocate.Args.Month, allocate.Args.Group, allocate.Args.Amount = "2017-04", "Salary", 100
+	if err := allocate.Execute(nil); err == nil {
+		t.Error("want error when allocating to income group")
+	}
+
+	var stdout bytes.Buffer
+	stderr.Reset()
+	ls := List{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr), Color: "never"},
+		Since:   "2017-04-01",
+		Until:   "2017-04-30",
+	}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+----------------+---------+---------+-----------+-------+-----------+
+|     GROUP      | RECORDS | CARRIED | ALLOCATED | SPENT | AVAILABLE |
++----------------+---------+---------+-----------+-------+-----------+
+| Shopping       |       1 |  958.00 |    500.00 | 42.00 |   1500.00 |
++----------------+---------+---------+-----------+-------+-----------+
+| Total          |       1 |  958.00 |    500.00 | 42.00 |   1500.00 |
+| To be budgeted |         |         |           |       |   -163.00 |
++----------------+---------+---------+-----------+-------+-----------+
+`
+	testString(t, stdout.String(), want)
+	testString(t, stderr.String(), "journal: displaying records for all accounts between 2017-04-01 and 2017-04-30\n"+
+		"journal: warning: allocations exceed income by 163.00\n")
+
+	// Records are filtered by account type
+	stdout.Reset()
+	ls.Type = "savings"
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want = `+----------------+---------+---------+-----------+-------+-----------+
+|     GROUP      | RECORDS | CARRIED | ALLOCATED | SPENT | AVAILABLE |
++----------------+---------+---------+-----------+-------+-----------+
+| Shopping       |       0 | 1000.00 |    500.00 |  0.00 |   1500.00 |
++----------------+---------+---------+-----------+-------+-----------+
+| Total          |       0 | 1000.00 |    500.00 |  0.00 |   1500.00 |
+| To be budgeted |         |         |           |       |  -1500.00 |
++----------------+---------+---------+-----------+-------+-----------+
+`
+	testString(t, stdout.String(), want)
+
+	// Hidden groups are not shown
+	stdout.Reset()
+	stderr.Reset()
+	ls.Type = ""
+	ls.HideGroups = []string{"Shopping"}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	testString(t, stdout.String(), "")
+	testString(t, stderr.String(), "journal: displaying records for all accounts between 2017-04-01 and 2017-04-30\n"+
+		"journal: warning: allocations exceed income by 163.00\n"+
+		"journal: 0 envelopes found\n")
+}
+
+func TestListAmortized(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "A"
+
+  [[groups.rules]]
+  pattern = "Transaction 1"
+  amortize = 2
+
+[[groups]]
+name = "B"
+patterns = ["Transaction [2-3]"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	ls := List{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr), Color: "never"},
+		Since:   "2017-03-01",
+		Until:   "2017-03-31",
+		Explain: "all",
+	}
+	if err := ls.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+---------------+--------------+------------+------------+-------+-------------------+---------------------+--------+
+|    ACCOUNT    | ACCOUNT NAME |     ID     |    DATE    | GROUP |       RULE        |        TEXT         | AMOUNT |
++---------------+--------------+------------+------------+-------+-------------------+---------------------+--------+
+| 1234.56.78900 | My account 1 | 66e7fcce66 | 2017-03-10 | B     | Transaction [2-3] | Transaction 2       | -42.00 |
+| 1234.56.78900 | My account 1 | ed5c019f5d | 2017-03-01 | A     | Transaction 1     | Transaction 1 (2/2) | 668.50 |
++---------------+--------------+------------+------------+-------+-------------------+---------------------+--------+
+|                                                                                             TOTAL        | 626.50 |
++---------------+--------------+------------+------------+-------+-------------------+---------------------+--------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestGoals(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "Savings"
+patterns = ["Transaction [2-3]"]
+
+[[goals]]
+name = "Vacation"
+target = 10000
+date = "2017-12-31"
+since = "2017-01-01"
+group = "Savings"
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	goals := Goals{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+		Date:    "2017-03-31",
+	}
+	if err := goals.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+----------+--------+------------+-------+----------+-----------+---------+--------+
+|   GOAL   | TARGET |    DATE    | SAVED | PROGRESS | REMAINING | MONTHLY | STATUS |
++----------+--------+------------+-------+----------+-----------+---------+--------+
+| Vacation | 100.00 | 2017-12-31 | 42.00 |      42% |     58.00 |    6.45 | ahead  |
++----------+--------+------------+-------+----------+-----------+---------+--------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestAlert(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "Shopping"
+budget = %d
+patterns = ["Transaction 2"]
+`
+	var tests = []struct {
+		budget int64
+		out    string
+		err    string
+	}{
+		{-10000, "", ""},
+		{-5000, "warning: Shopping: -42.00 of -50.00 (84%) since 2017-03-01\n", ""},
+		{-4000, "over: Shopping: -42.00 of -40.00 (105%) since 2017-03-01\n", "1 group(s) over budget"},
+	}
+	for i, tt := range tests {
+		if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db, tt.budget), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			importFile(t, f, ioutil.Discard, ioutil.Discard)
+		}
+		var stdout, stderr bytes.Buffer
+		alert := Alert{
+			Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+			Date:    "2017-03-15",
+		}
+		err := alert.Execute(nil)
+		if tt.err == "" && err != nil {
+			t.Errorf("#%d: unexpected error: %s", i, err)
+		} else if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("#%d: want error %q, got %v", i, tt.err, err)
+		}
+		testString(t, stdout.String(), tt.out)
+	}
+}
+
+func TestTransfers(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[accounts]]
+number = "1234.56.78901"
+name = "My account 2"
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	data := filepath.Join(f.dir, "data2")
+	if err := ioutil.WriteFile(data, []byte(`"11.03.2017";"11.03.2017";"Transfer from account 1";"42,00";"42,00";"";""
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var stderr bytes.Buffer
+	imp := Import{Options: Options{Config: f.conf, Writer: ioutil.Discard, Log: NewLogger(&stderr)}, Reader: "csv"}
+	imp.Args.Account = "1234.56.78901"
+	imp.Args.Files = []string{data}
+	if err := imp.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "detected 1 new transfer(s)") {
+		t.Errorf("want detected transfer, got %q", stderr.String())
+	}
+
+	var stdout bytes.Buffer
+	transfers := Transfers{Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(ioutil.Discard)}}
+	if err := transfers.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+------------+--------------+--------------+--------+---------------+
+|    DATE    |     FROM     |      TO      | AMOUNT |     TEXT      |
++------------+--------------+--------------+--------+---------------+
+| 2017-03-10 | My account 1 | My account 2 |  42.00 | Transaction 2 |
++------------+--------------+--------------+--------+---------------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestRecurring(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	data := `"15.01.2017";"15.01.2017";"Spotify";"-99,00";"0,00";"";""
+"15.02.2017";"15.02.2017";"Spotify";"-99,00";"0,00";"";""
+"15.03.2017";"15.03.2017";"Spotify";"-99,00";"0,00";"";""
+"15.04.2017";"15.04.2017";"Spotify";"-119,00";"0,00";"";""
+`
+	if err := ioutil.WriteFile(f.data, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout bytes.Buffer
+	recurring := Recurring{Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(ioutil.Discard)}, MinCount: 3}
+	if err := recurring.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+---------+---------+--------+---------+------------+------------+-------------------------------+
+|  NAME   | CADENCE | AMOUNT | CHARGES |    LAST    |    NEXT    |         PRICE CHANGES         |
++---------+---------+--------+---------+------------+------------+-------------------------------+
+| Spotify | monthly | -99.00 |       4 | 2017-04-15 | 2017-05-15 | 2017-04-15: -99.00 -> -119.00 |
++---------+---------+--------+---------+------------+------------+-------------------------------+
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestForecast(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+floor = 100000
+
+[[recurring]]
+name = "Rent"
+account = "1234.56.78900"
+amount = -50000
+cadence = "monthly"
+date = "2017-05-15"
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout, stderr bytes.Buffer
+	forecast := Forecast{
+		Options: Options{Config: f.conf, Writer: &stdout, Log: NewLogger(&stderr)},
+		Months:  1,
+		Date:    "2017-04-20",
+	}
+	if err := forecast.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want := `+------------+--------------+
+|    DATE    | MY ACCOUNT 1 |
++------------+--------------+
+| 2017-04-20 |      1337.00 |
+| 2017-04-30 |      1337.00 |
+| 2017-05-31 |       837.00 |
++------------+--------------+
+`
+	testString(t, stdout.String(), want)
+	testString(t, stderr.String(), "journal: warning: My account 1 is projected to fall below 1000.00 on 2017-05-15 (837.00)\n")
+
+	stdout.Reset()
+	forecast.CSV = true
+	forecast.Daily = true
+	forecast.Months = 0
+	forecast.Date = "2017-04-28"
+	if err := forecast.Execute(nil); err != nil {
+		t.Fatal(err)
+	}
+	want = `Date,My account 1
+2017-04-28,1337.00
+2017-04-29,1337.00
+2017-04-30,1337.00
+`
+	testString(t, stdout.String(), want)
+}
+
+func TestAnomalies(t *testing.T) {
+	f := testFiles(t)
+	defer f.removeAll()
+	conf := `
+Database = "%s"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "Electricity"
+patterns = ["^Power"]
+`
+	if err := ioutil.WriteFile(f.conf, fmt.Appendf(nil, conf, f.db), 0644); err != nil {
+		t.Fatal(err)
+	}
+	var data strings.Builder
+	for i, amount := range []string{"-580,00", "-600,00", "-610,00", "-590,00", "-600,00", "-620,00", "-1.200,00"} {
+		fmt.Fprintf(&data, "\"05.%02d.2017\";\"05.%02d.2017\";\"Power company\";\"%s\";\"0,00\";\"\";\"\"\n", i+1, i+1, amount)
+	}
+	if err := ioutil.WriteFile(f.data, []byte(data.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	importFile(t, f, ioutil.Discard, ioutil.Discard)
+
+	var stdout bytes.Buffer
+	anomalies := Anomalies{
+		Options:   Options{Config: f.conf, Writer: &stdout, Log: NewLogger(ioutil.Discard)},
+		Since:     "2017-07-01",
+		Until:     "2017-07-31",
+		Months:    12,
+		Threshold: 3.5,
+	}
+	if err := anomalies.Execute(nil); err == nil || err.Error() != "found 1 anomalies" {
+		t.Errorf("want error for anomalies, got %v", err)
+	}
+	want := `+------------+--------+-------------+---------------+----------+---------+-------+
+|    DATE    |  KIND  |    GROUP    |   MERCHANT    |  AMOUNT  |  USUAL  | SCORE |
++------------+--------+-------------+---------------+----------+---------+-------+
+| 2017-07-05 | amount | Electricity | Power Company | -1200.00 | -600.00 |  20.0 |
++------------+--------+-------------+---------------+----------+---------+-------+
+`
+	testString(t, stdout.String(), want)
+
+	stdout.Reset()
+	alert := Alert{
+		Options:   Options{Config: f.conf, Writer: &stdout, Log: NewLogger(ioutil.Discard)},
+		Date:      "2017-07-10",
+		Anomalies: true,
+	}
+	if err := alert.Execute(nil); err == nil || err.Error() != "found 1 anomalies" {
+		t.Errorf("want error for anomalies, got %v", err)
+	}
+	testString(t, stdout.String(), "anomaly: Electricity: Power Company: amount -1200.00 on 2017-07-05, usually -600.00\n")
+}
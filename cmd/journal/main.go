@@ -1,6 +1,7 @@
 package main
 
 import (
+	"io"
 	"os"
 
 	"github.com/jessevdk/go-flags"
@@ -8,37 +9,177 @@ import (
 )
 
 func main() {
-	p := flags.NewParser(nil, flags.HelpFlag|flags.PassDoubleDash)
 	log := cmd.NewLogger(os.Stderr)
 	isPipe, err := isPipe(os.Stdout)
 	if err != nil {
 		log.Fatal(err)
 	}
 	opts := cmd.Options{Log: log, Writer: os.Stdout, IsPipe: isPipe}
+	p, err := newParser(opts, os.Stdin)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if _, err := p.Parse(); err != nil {
+		log.Fatal(err)
+	}
+}
 
+// newParser creates a parser for all commands, sharing the given options.
+func newParser(opts cmd.Options, stdin io.Reader) (*flags.Parser, error) {
+	p := flags.NewParser(nil, flags.HelpFlag|flags.PassDoubleDash)
 	imp := cmd.Import{Options: opts}
 	if _, err := p.AddCommand("import", "Import records", "Imports records into the database.", &imp); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	export := cmd.Export{Options: opts}
 	if _, err := p.AddCommand("export", "Export records", "Export records to CSV.", &export); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	acct := cmd.Accounts{Options: opts}
-	if _, err := p.AddCommand("acct", "List accounts", "Display accounts in database", &acct); err != nil {
-		log.Fatal(err)
+	acctCmd, err := p.AddCommand("acct", "List accounts", "Display accounts in database", &acct)
+	if err != nil {
+		return nil, err
+	}
+	acctCmd.SubcommandsOptional = true
+
+	renameAcct := cmd.RenameAccount{Options: opts}
+	if _, err := acctCmd.AddCommand("rename", "Rename account", "Rename account in database", &renameAcct); err != nil {
+		return nil, err
+	}
+
+	rmAcct := cmd.RemoveAccount{Options: opts}
+	if _, err := acctCmd.AddCommand("rm", "Remove account", "Remove account from database", &rmAcct); err != nil {
+		return nil, err
+	}
+
+	mergeAcct := cmd.MergeAccounts{Options: opts}
+	if _, err := acctCmd.AddCommand("merge", "Merge accounts", "Move records from one account to another and remove the former", &mergeAcct); err != nil {
+		return nil, err
 	}
 
 	list := cmd.List{Options: opts}
 	if _, err := p.AddCommand("ls", "List records", "Display records in database", &list); err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	if _, err := p.Parse(); err != nil {
-		log.Fatal(err)
+	groupCmd, err := p.AddCommand("group", "Manage groups", "Manage groups and rules stored in the database", &struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	lsGroups := cmd.ListGroups{Options: opts}
+	if _, err := groupCmd.AddCommand("ls", "List groups", "List groups from config and database, in order of precedence", &lsGroups); err != nil {
+		return nil, err
+	}
+
+	addGroup := cmd.AddGroup{Options: opts}
+	if _, err := groupCmd.AddCommand("add", "Add group", "Add group to database", &addGroup); err != nil {
+		return nil, err
+	}
+
+	rmGroup := cmd.RemoveGroup{Options: opts}
+	if _, err := groupCmd.AddCommand("rm", "Remove group", "Remove group and its rules from database", &rmGroup); err != nil {
+		return nil, err
+	}
+
+	pin := cmd.PinRecord{Options: opts}
+	if _, err := groupCmd.AddCommand("pin", "Pin record", "Pin record to group", &pin); err != nil {
+		return nil, err
+	}
+
+	unpin := cmd.UnpinRecord{Options: opts}
+	if _, err := groupCmd.AddCommand("unpin", "Unpin record", "Remove pins of record from database", &unpin); err != nil {
+		return nil, err
+	}
+
+	patternCmd, err := groupCmd.AddCommand("pattern", "Manage patterns", "Manage group patterns stored in the database", &struct{}{})
+	if err != nil {
+		return nil, err
 	}
+
+	addPattern := cmd.AddPattern{Options: opts}
+	if _, err := patternCmd.AddCommand("add", "Add pattern", "Add pattern to group", &addPattern); err != nil {
+		return nil, err
+	}
+
+	configCmd, err := p.AddCommand("config", "Inspect config", "Inspect the config file", &struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	checkConfig := cmd.CheckConfig{Options: opts}
+	if _, err := configCmd.AddCommand("check", "Check config", "Report all problems in the config file and the files it includes", &checkConfig); err != nil {
+		return nil, err
+	}
+
+	rulesCmd, err := p.AddCommand("rules", "Inspect group rules", "Inspect how group rules match records", &struct{}{})
+	if err != nil {
+		return nil, err
+	}
+
+	explain := cmd.ExplainRule{Options: opts}
+	if _, err := rulesCmd.AddCommand("explain", "Explain grouping", "Display the pins and rules matching a record, in order of precedence", &explain); err != nil {
+		return nil, err
+	}
+
+	lint := cmd.LintRules{Options: opts}
+	if _, err := rulesCmd.AddCommand("lint", "Lint rules", "Report unused, shadowed and otherwise problematic rules", &lint); err != nil {
+		return nil, err
+	}
+
+	suggest := cmd.SuggestRules{Options: opts}
+	if _, err := rulesCmd.AddCommand("suggest", "Suggest rules", "Suggest group rules for records in the default group", &suggest); err != nil {
+		return nil, err
+	}
+
+	categorize := cmd.Categorize{Options: opts, Input: stdin}
+	if _, err := p.AddCommand("categorize", "Categorize records", "Interactively assign ungrouped records to groups and save the result to the config file", &categorize); err != nil {
+		return nil, err
+	}
+
+	classify := cmd.Classify{Options: opts}
+	if _, err := p.AddCommand("classify", "Classify records", "Propose groups for records in the default group, based on already grouped records", &classify); err != nil {
+		return nil, err
+	}
+
+	allocate := cmd.Allocate{Options: opts}
+	if _, err := p.AddCommand("allocate", "Allocate to envelope", "Allocate an amount to a group in a month, when using envelope budgeting", &allocate); err != nil {
+		return nil, err
+	}
+
+	goals := cmd.Goals{Options: opts}
+	if _, err := p.AddCommand("goals", "Show savings goals", "Display progress towards savings goals", &goals); err != nil {
+		return nil, err
+	}
+
+	alert := cmd.Alert{Options: opts}
+	if _, err := p.AddCommand("alert", "Check budgets", "Report groups close to or over budget and exit with a non-zero status if any group is over", &alert); err != nil {
+		return nil, err
+	}
+
+	transfers := cmd.Transfers{Options: opts}
+	if _, err := p.AddCommand("transfers", "List transfers", "Detect and list transfers between accounts", &transfers); err != nil {
+		return nil, err
+	}
+
+	recurring := cmd.Recurring{Options: opts}
+	if _, err := p.AddCommand("recurring", "List recurring payments", "Find subscriptions and other payments charged at a regular interval", &recurring); err != nil {
+		return nil, err
+	}
+
+	forecast := cmd.Forecast{Options: opts}
+	if _, err := p.AddCommand("forecast", "Forecast balances", "Project account balances from budgets and recurring payments", &forecast); err != nil {
+		return nil, err
+	}
+
+	anomalies := cmd.Anomalies{Options: opts}
+	if _, err := p.AddCommand("anomalies", "Find anomalies", "Report unusual records and monthly sums compared to earlier months", &anomalies); err != nil {
+		return nil, err
+	}
+
+	return p, nil
 }
 
 func isPipe(f *os.File) (bool, error) {
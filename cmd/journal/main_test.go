@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/mpolden/journal/cmd"
+)
+
+func TestParser(t *testing.T) {
+	opts := cmd.Options{Log: cmd.NewLogger(ioutil.Discard), Writer: ioutil.Discard}
+	p, err := newParser(opts, strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var check func(names []string, cs []*flags.Command)
+	check = func(names []string, cs []*flags.Command) {
+		for _, c := range cs {
+			args := append(append([]string(nil), names...), c.Name)
+			_, err := p.ParseArgs(append(args, "--help"))
+			if e, ok := err.(*flags.Error); !ok || e.Type != flags.ErrHelp {
+				t.Errorf("%s: want help, got %v", strings.Join(args, " "), err)
+			}
+			check(args, c.Commands())
+		}
+	}
+	check(nil, p.Commands())
+}
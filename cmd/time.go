@@ -50,3 +50,14 @@ func (c *clock) timeRange(since, until string) (time.Time, time.Time, error) {
 	}
 	return s, u, nil
 }
+
+// elapsed returns the end of the elapsed part of the time range s to u. If the range contains the current time, the
+// range has elapsed until today.
+func (c *clock) elapsed(s, u time.Time) time.Time {
+	now := c.now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if today.Before(s) || !today.Before(u) {
+		return u
+	}
+	return today
+}
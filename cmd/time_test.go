@@ -96,3 +96,22 @@ func TestTimeRange(t *testing.T) {
 		}
 	}
 }
+
+func TestElapsed(t *testing.T) {
+	testClock := &clock{now: func() time.Time { return date(2018, 12, 15).Add(13 * time.Hour) }}
+	var tests = []struct {
+		s   time.Time
+		u   time.Time
+		out time.Time
+	}{
+		{date(2018, 12, 1), date(2018, 12, 31), date(2018, 12, 15)},
+		{date(2018, 11, 1), date(2018, 11, 30), date(2018, 11, 30)},
+		{date(2019, 1, 1), date(2019, 1, 31), date(2019, 1, 31)},
+		{date(2018, 12, 1), date(2018, 12, 10), date(2018, 12, 10)},
+	}
+	for i, tt := range tests {
+		if got := testClock.elapsed(tt.s, tt.u); !tt.out.Equal(got) {
+			t.Errorf("#%d: got %s, want %s", i, got, tt.out)
+		}
+	}
+}
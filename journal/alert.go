@@ -0,0 +1,203 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"sort"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+const defaultWarnAt = 0.8
+
+// Alert configures budget alerts.
+type Alert struct {
+	WarnAt  float64 // Fraction of budget used at which a group is reported. Defaults to 0.8
+	Webhook string  // URL receiving a JSON payload when there are alerts
+	Command string  // Command receiving a JSON payload on standard input when there are alerts
+}
+
+// A BudgetAlert is a group that has used at least its warning threshold of the budget in the current budget period.
+type BudgetAlert struct {
+	Group   string
+	Period  record.Range // The budget period being evaluated
+	Sum     int64        // Sum of records in the period
+	Budget  int64        // Budget of the period, including any carried balance
+	Balance int64
+	Used    float64 // Fraction of budget used
+}
+
+func (a *Alert) load() error {
+	if a.WarnAt < 0 {
+		return fmt.Errorf("invalid alert warnAt: %f", a.WarnAt)
+	}
+	if a.Webhook != "" {
+		u, err := url.Parse(a.Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid alert webhook: %q", a.Webhook)
+		}
+	}
+	return nil
+}
+
+// Over returns whether the group has exceeded its budget.
+func (a *BudgetAlert) Over() bool { return a.Balance > 0 }
+
+// Alerts evaluates the budget of each group with a budget of its own in the budget period containing now, and returns
+// the groups that have used at least their warning threshold. The threshold is given by the group's warnAt, or the
+// alert warnAt if the group has none. Income groups, and groups without a negative budget, are not evaluated. Only
+// records selected by the journal's filter are considered.
+func (j *Journal) Alerts(now time.Time) ([]BudgetAlert, error) {
+	var gs []record.Group
+	warnAt := make(map[string]float64)
+	for _, name := range j.GroupNames() {
+		g := j.configGroup(name)
+		rg := recordGroup(*g)
+		if g.Income || !rg.HasBudget() || rg.Budget(record.PeriodRange(rg.BudgetPeriod(), now)) >= 0 {
+			continue
+		}
+		gs = append(gs, rg)
+		warnAt[name] = j.warnAt(g)
+	}
+	if len(gs) == 0 {
+		return nil, nil
+	}
+	if err := j.Carry(gs, nil, now); err != nil {
+		return nil, err
+	}
+	var alerts []BudgetAlert
+	records := make(map[string][]record.Record)
+	for _, g := range gs {
+		period := record.PeriodRange(g.BudgetPeriod(), now)
+		rs, ok := records[g.BudgetPeriod()]
+		if !ok {
+			var err error
+			if rs, err = j.ReadAmortized(nil, period.Since, now); err != nil {
+				return nil, err
+			}
+			rs = j.filter(rs)
+			records[g.BudgetPeriod()] = rs
+		}
+		for _, r := range rs {
+			if j.recordInGroup(r, g.Name) {
+				g.Records = append(g.Records, r)
+			}
+		}
+		a := BudgetAlert{
+			Group:   g.Name,
+			Period:  period,
+			Sum:     g.Sum(),
+			Budget:  g.Budget(period) + g.Carried(),
+			Balance: g.Balance(period),
+		}
+		switch {
+		case a.Budget < 0:
+			a.Used = float64(a.Sum) / float64(a.Budget)
+		case a.Over():
+			a.Used = 1 // Carried surplus has been spent
+		}
+		if a.Used >= warnAt[g.Name] || a.Over() {
+			alerts = append(alerts, a)
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Used > alerts[j].Used })
+	return alerts, nil
+}
+
+func (j *Journal) warnAt(g *Group) float64 {
+	if g.WarnAt > 0 {
+		return g.WarnAt
+	}
+	if j.alert.WarnAt > 0 {
+		return j.alert.WarnAt
+	}
+	return defaultWarnAt
+}
+
+type alertPayload struct {
+	Time      string        `json:"time"`
+	Alerts    []alertJSON   `json:"alerts"`
+	Anomalies []anomalyJSON `json:"anomalies,omitempty"`
+}
+
+type alertJSON struct {
+	Group   string  `json:"group"`
+	Since   string  `json:"since"`
+	Until   string  `json:"until"`
+	Sum     int64   `json:"sum"`
+	Budget  int64   `json:"budget"`
+	Balance int64   `json:"balance"`
+	Used    float64 `json:"used"`
+	Over    bool    `json:"over"`
+}
+
+type anomalyJSON struct {
+	Kind     string  `json:"kind"`
+	Group    string  `json:"group"`
+	Merchant string  `json:"merchant,omitempty"`
+	Date     string  `json:"date"`
+	Amount   int64   `json:"amount"`
+	Median   int64   `json:"median"`
+	Score    float64 `json:"score"`
+}
+
+// Notify sends alerts and anomalies to the configured webhook and command. The webhook receives a JSON payload in a
+// POST request, and the command is run by the shell with the same payload on standard input. Notify does nothing if
+// both alerts and anomalies are empty.
+func (j *Journal) Notify(alerts []BudgetAlert, anomalies []Anomaly, now time.Time) error {
+	if (len(alerts) == 0 && len(anomalies) == 0) || (j.alert.Webhook == "" && j.alert.Command == "") {
+		return nil
+	}
+	payload := alertPayload{Time: now.Format(time.RFC3339), Alerts: []alertJSON{}}
+	for _, a := range alerts {
+		payload.Alerts = append(payload.Alerts, alertJSON{
+			Group:   a.Group,
+			Since:   a.Period.Since.Format("2006-01-02"),
+			Until:   a.Period.Until.Format("2006-01-02"),
+			Sum:     a.Sum,
+			Budget:  a.Budget,
+			Balance: a.Balance,
+			Used:    a.Used,
+			Over:    a.Over(),
+		})
+	}
+	for _, a := range anomalies {
+		payload.Anomalies = append(payload.Anomalies, anomalyJSON{
+			Kind:     a.Kind,
+			Group:    a.Group,
+			Merchant: a.Merchant,
+			Date:     a.Time.Format("2006-01-02"),
+			Amount:   a.Amount,
+			Median:   a.Median,
+			Score:    a.Score,
+		})
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	if j.alert.Webhook != "" {
+		client := http.Client{Timeout: 10 * time.Second}
+		res, err := client.Post(j.alert.Webhook, "application/json", bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		res.Body.Close()
+		if res.StatusCode/100 != 2 {
+			return fmt.Errorf("alert webhook %s: unexpected status: %s", j.alert.Webhook, res.Status)
+		}
+	}
+	if j.alert.Command != "" {
+		cmd := exec.Command("sh", "-c", j.alert.Command)
+		cmd.Stdin = bytes.NewReader(data)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("alert command %q: %w: %s", j.alert.Command, err, bytes.TrimSpace(out))
+		}
+	}
+	return nil
+}
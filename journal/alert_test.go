@@ -0,0 +1,200 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func testAlertJournal(t *testing.T, alert string) *Journal {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+` + alert + `
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[accounts]]
+number = "4.5.6"
+name = "Old checking"
+closed = true
+
+[[groups]]
+name = "Groceries"
+budget = -5000
+patterns = ["^Groceries"]
+
+[[groups]]
+name = "Restaurants"
+budget = -2000
+warnAt = 0.5
+patterns = ["^Restaurant"]
+
+[[groups]]
+name = "Clothes"
+budget = -10000
+patterns = ["^Clothes"]
+
+[[groups]]
+name = "Travel"
+budget = -30000
+budgetPeriod = "year"
+patterns = ["^Travel"]
+
+[[groups]]
+name = "Salary"
+budget = 30000
+income = true
+patterns = ["^Salary"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("1.2.3", []record.Record{
+		{Time: date(2018, 6, 28), Text: "Groceries", Amount: -4000}, // Before period
+		{Time: date(2018, 7, 2), Text: "Groceries", Amount: -3000},
+		{Time: date(2018, 7, 9), Text: "Groceries", Amount: -2600},
+		{Time: date(2018, 7, 20), Text: "Groceries", Amount: -1000}, // After now
+		{Time: date(2018, 7, 3), Text: "Restaurant", Amount: -1100},
+		{Time: date(2018, 7, 4), Text: "Clothes", Amount: -7000},
+		{Time: date(2018, 2, 1), Text: "Travel", Amount: -25000},
+		{Time: date(2018, 7, 1), Text: "Salary", Amount: 30000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("4.5.6", []record.Record{
+		{Time: date(2018, 7, 5), Text: "Clothes", Amount: -5000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestAlerts(t *testing.T) {
+	j := testAlertJournal(t, "")
+	alerts, err := j.Alerts(date(2018, 7, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		group   string
+		sum     int64
+		budget  int64
+		balance int64
+		used    string
+		over    bool
+	}{
+		{"Groceries", -5600, -5000, 600, "1.12", true},
+		{"Travel", -25000, -30000, -5000, "0.83", false},
+		{"Restaurants", -1100, -2000, -900, "0.55", false},
+	}
+	if len(alerts) != len(tests) {
+		t.Fatalf("want %d alerts, got %d: %+v", len(tests), len(alerts), alerts)
+	}
+	for i, tt := range tests {
+		a := alerts[i]
+		used := fmt.Sprintf("%.2f", a.Used)
+		if a.Group != tt.group || a.Sum != tt.sum || a.Budget != tt.budget || a.Balance != tt.balance || used != tt.used || a.Over() != tt.over {
+			t.Errorf("#%d: want %s: sum = %d, budget = %d, balance = %d, used = %s, over = %t; got %s: sum = %d, budget = %d, balance = %d, used = %s, over = %t",
+				i, tt.group, tt.sum, tt.budget, tt.balance, tt.used, tt.over, a.Group, a.Sum, a.Budget, a.Balance, used, a.Over())
+		}
+	}
+	if want, got := date(2018, 1, 1), alerts[1].Period.Since; !want.Equal(got) {
+		t.Errorf("want period since %s, got %s", want, got)
+	}
+
+	// Records in closed accounts are only counted if the filter selects them
+	j.Filter.Closed = true
+	if alerts, err = j.Alerts(date(2018, 7, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != len(tests)+1 || alerts[0].Group != "Clothes" {
+		t.Errorf("want alert for Clothes, got %+v", alerts)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %s", r.Method, r.Header.Get("Content-Type"))
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	out := filepath.Join(t.TempDir(), "payload.json")
+	j := testAlertJournal(t, fmt.Sprintf(`
+[alert]
+webhook = %q
+command = "cat > %s"
+`, srv.URL, out))
+	alerts, err := j.Alerts(date(2018, 7, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Notify(alerts, nil, date(2018, 7, 10)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != string(body) {
+		t.Errorf("want command payload %s, got %s", body, data)
+	}
+	var payload struct {
+		Time   string
+		Alerts []struct {
+			Group string
+			Since string
+			Over  bool
+		}
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(payload.Alerts), 3; got != want {
+		t.Fatalf("want %d alerts, got %d", want, got)
+	}
+	if a := payload.Alerts[0]; a.Group != "Groceries" || a.Since != "2018-07-01" || !a.Over {
+		t.Errorf("unexpected alert: %+v", a)
+	}
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusInternalServerError) })
+	if err := j.Notify(alerts, nil, date(2018, 7, 10)); err == nil {
+		t.Error("want error for failing webhook")
+	}
+}
+
+func TestAlertConfig(t *testing.T) {
+	var tests = []struct {
+		conf string
+		err  string
+	}{
+		{"[alert]\nwarnAt = -0.1", "invalid alert warnAt: -0.100000"},
+		{"[alert]\nwebhook = \"ftp://example.com\"", `invalid alert webhook: "ftp://example.com"`},
+		{"[[groups]]\nname = \"A\"\nwarnAt = -1", `group: "A": invalid warnAt: -1.000000`},
+	}
+	for i, tt := range tests {
+		conf, err := readConfig(strings.NewReader("Database = \":memory:\"\n" + tt.conf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = conf.load()
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("#%d: want error containing %q, got %v", i, tt.err, err)
+		}
+	}
+}
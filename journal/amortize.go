@@ -0,0 +1,82 @@
+package journal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+// An Amortization spreads the records identified by IDs over a number of months.
+type Amortization struct {
+	IDs    []string
+	Months int
+}
+
+func (a *Amortization) load() error {
+	if len(a.IDs) == 0 {
+		return fmt.Errorf("no ids to amortize")
+	}
+	if a.Months < 1 {
+		return fmt.Errorf("invalid number of months to amortize over: %d", a.Months)
+	}
+	return nil
+}
+
+func (j *Journal) loadAmortize(amortizations []Amortization) {
+	j.amortize = make(map[string]int)
+	j.maxAmortize = 0
+	for _, a := range amortizations {
+		for _, id := range a.IDs {
+			j.amortize[id] = a.Months
+		}
+		j.maxAmortize = max(j.maxAmortize, a.Months)
+	}
+	for _, g := range j.groups {
+		for _, rule := range g.rules {
+			j.maxAmortize = max(j.maxAmortize, rule.Amortize)
+		}
+	}
+}
+
+// amortization returns the number of months that record r is amortized over. Amortizations declared by ID take
+// precedence over the rule assorting r into its group.
+func (j *Journal) amortization(r record.Record) int {
+	if months, ok := j.amortize[r.ID()]; ok {
+		return months
+	}
+	months := 0
+	j.visit(r, func(g *Group, rule *Rule, matched bool) bool {
+		if matched && rule != nil {
+			months = rule.Amortize
+		}
+		return !matched
+	})
+	return months
+}
+
+// ReadAmortized is like Read, but replaces amortized records with slices spread over the following months. Only slices
+// occurring between since and until are returned, including slices of records occurring before since.
+func (j *Journal) ReadAmortized(accountNumbers []string, since, until time.Time) ([]record.Record, error) {
+	if j.maxAmortize < 2 {
+		return j.Read(accountNumbers, since, until)
+	}
+	from := since
+	if !since.IsZero() {
+		from = monthStart(since).AddDate(0, 1-j.maxAmortize, 0)
+	}
+	rs, err := j.Read(accountNumbers, from, until)
+	if err != nil {
+		return nil, err
+	}
+	var amortized []record.Record
+	for _, r := range rs {
+		for _, s := range r.Amortize(j.amortization(r)) {
+			if s.Time.Before(since) || (!until.IsZero() && s.Time.After(until)) {
+				continue
+			}
+			amortized = append(amortized, s)
+		}
+	}
+	return amortized, nil
+}
@@ -0,0 +1,79 @@
+package journal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestReadAmortized(t *testing.T) {
+	laptop := record.Record{Account: record.Account{Number: "1.2.3"}, Time: date(2018, 1, 10), Text: "Laptop", Amount: -12000}
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[amortize]]
+ids = ["` + laptop.ID() + `"]
+months = 12
+
+[[groups]]
+name = "Electronics"
+patterns = ["^Laptop"]
+
+[[groups]]
+name = "Insurance"
+
+  [[groups.rules]]
+  pattern = "^Insurance"
+  amortize = 3
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := []record.Record{
+		laptop,
+		{Time: date(2018, 2, 1), Text: "Insurance", Amount: -3000},
+		{Time: date(2018, 3, 5), Text: "Laptop bag", Amount: -500},
+	}
+	if _, err := j.Write("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	amortized, err := j.ReadAmortized(nil, date(2018, 3, 1), date(2018, 3, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums := make(map[string]int64)
+	for _, g := range j.Assort(amortized) {
+		sums[g.Name] = g.Sum()
+	}
+	if want, got := int64(-1000-500), sums["Electronics"]; want != got {
+		t.Errorf("want Electronics sum %d, got %d", want, got)
+	}
+	if want, got := int64(-1000), sums["Insurance"]; want != got {
+		t.Errorf("want Insurance sum %d, got %d", want, got)
+	}
+	for _, r := range amortized {
+		if r.Text == "Laptop" && (r.Slice == nil || r.Slice.N != 3 || r.Slice.Original.ID() != laptop.ID()) {
+			t.Errorf("want slice 3 of %s, got %+v", laptop.ID(), r.Slice)
+		}
+		if m, ok := j.MatchOf(r); r.Text == "Laptop" && (!ok || m.Group != "Electronics") {
+			t.Errorf("want slice to match Electronics, got %+v", m)
+		}
+	}
+
+	all, err := j.ReadAmortized(nil, date(2018, 1, 1), date(2018, 12, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := 12+3+1, len(all); want != got {
+		t.Errorf("want %d records, got %d", want, got)
+	}
+}
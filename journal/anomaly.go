@@ -0,0 +1,294 @@
+package journal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+const (
+	// AmountAnomaly is the kind of an anomaly where a record has an unusual amount.
+	AmountAnomaly = "amount"
+
+	// MonthAnomaly is the kind of an anomaly where the sum of a group or merchant in a month is unusual.
+	MonthAnomaly = "month"
+
+	// DuplicateAnomaly is the kind of an anomaly where a record looks like a duplicate of another record.
+	DuplicateAnomaly = "duplicate"
+)
+
+const (
+	minAmountSamples = 5 // Number of records required to judge the amount of a record
+	minMonthSamples  = 3 // Number of months with records required to judge the sum of a month
+	duplicateWindow  = 2 * 24 * time.Hour
+
+	defaultAnomalyMonths    = 12
+	defaultAnomalyThreshold = 3.5
+)
+
+// An Anomaly is a record, or a monthly sum of records, that deviates from the history of its group or merchant.
+type Anomaly struct {
+	Kind     string
+	Group    string
+	Merchant string    // Name of the merchant, unless this is an anomaly in the monthly sum of a group
+	Time     time.Time // Time of the record, or the first day of the month
+	Amount   int64     // Amount of the record, or sum of the month
+	Median   int64     // Median amount in the history. For duplicates, the amount of the original record
+	Score    float64   // Modified z-score of the amount. Zero for duplicates
+	Records  []record.Record
+}
+
+// stats are robust statistics of a series of amounts.
+type stats struct {
+	median int64
+	scale  float64 // Median absolute deviation, scaled to be comparable to a standard deviation
+}
+
+func median(xs []int64) int64 {
+	sorted := append([]int64(nil), xs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func newStats(xs []int64) stats {
+	m := median(xs)
+	devs := make([]int64, len(xs))
+	for i, x := range xs {
+		devs[i] = abs(x - m)
+	}
+	// A series of identical amounts has no deviation. Require a small relative change, so that every cent does not
+	// count as an outlier
+	scale := max(1.4826*float64(median(devs)), 0.05*float64(abs(m)))
+	return stats{median: m, scale: scale}
+}
+
+// score returns how far the magnitude of x exceeds the median, in units of scale. Amounts of the opposite sign of the
+// median, and amounts smaller than the median, score zero.
+func (s stats) score(x int64) float64 {
+	if s.scale == 0 || s.median == 0 || (x < 0) != (s.median < 0) {
+		return 0
+	}
+	return max(float64(abs(x)-abs(s.median))/s.scale, 0)
+}
+
+// merchant returns the key and the name of the merchant of record r.
+func merchant(r record.Record) (string, string) {
+	ts := tokens(r.Text)
+	if len(ts) == 0 {
+		return r.Text, r.Text
+	}
+	return clusterKey(ts), title(ts)
+}
+
+// series holds the records of a group or merchant in a history of months.
+type series struct {
+	name    string
+	amounts []int64
+	months  map[time.Time][]record.Record
+}
+
+func (s *series) add(r record.Record) {
+	if s.months == nil {
+		s.months = make(map[time.Time][]record.Record)
+	}
+	s.amounts = append(s.amounts, r.Amount)
+	m := monthStart(r.Time)
+	s.months[m] = append(s.months[m], r)
+}
+
+// monthlyStats returns statistics of the monthly sums of this series in the given months. Months without records
+// count as zero.
+func (s *series) monthlyStats(months []time.Time) (stats, bool) {
+	if len(s.months) < minMonthSamples {
+		return stats{}, false
+	}
+	sums := make([]int64, len(months))
+	for i, m := range months {
+		for _, r := range s.months[m] {
+			sums[i] += r.Amount
+		}
+	}
+	return newStats(sums), true
+}
+
+// monthlyCount returns the median number of records in the months of this series that have records.
+func (s *series) monthlyCount() int64 {
+	var counts []int64
+	for _, rs := range s.months {
+		counts = append(counts, int64(len(rs)))
+	}
+	if len(counts) == 0 {
+		return 0
+	}
+	return median(counts)
+}
+
+// Anomalies finds unusual records and monthly sums between since and until, compared to records in the given number
+// of months before the month containing since. The following are considered anomalies:
+//
+// A record whose amount has a modified z-score of at least threshold, compared to the amounts of earlier records of
+// the same merchant, or the same group if the merchant has too few records.
+//
+// A record with the same account, merchant and amount as another record at most two days earlier, unless the merchant
+// is usually charged more than once a month. The earlier record may occur before since.
+//
+// A month where the sum of a group, or of a merchant, has a modified z-score of at least threshold compared to earlier
+// months. Records that are already reported as anomalies are left out of the sum. A merchant month is not reported if
+// the merchant makes up the whole of an anomalous group month.
+//
+// Records that are discarded when assorting into groups, or not selected by the journal's filter, are ignored. Anomalies are ordered by time, newest first. If
+// months or threshold is zero, a history of 12 months and a threshold of 3.5 is used.
+func (j *Journal) Anomalies(since, until time.Time, months int, threshold float64) ([]Anomaly, error) {
+	if months == 0 {
+		months = defaultAnomalyMonths
+	}
+	if threshold == 0 {
+		threshold = defaultAnomalyThreshold
+	}
+	start := monthStart(since)
+	from := start.AddDate(0, -months, 0)
+	rs, err := j.Read(nil, from, until)
+	if err != nil {
+		return nil, err
+	}
+	rs = j.filter(rs)
+	var history []time.Time
+	for m := from; m.Before(start); m = m.AddDate(0, 1, 0) {
+		history = append(history, m)
+	}
+	groups := make(map[string]*series)
+	merchants := make(map[string]*series)
+	lookup := func(m map[string]*series, key, name string) *series {
+		s, ok := m[key]
+		if !ok {
+			s = &series{name: name}
+			m[key] = s
+		}
+		return s
+	}
+	var recent []record.Record // Records since the start of the duplicate window before since
+	recentGroups := make(map[string]*series)
+	recentMerchants := make(map[string]*series)
+	for _, r := range rs {
+		g := j.findGroup(r)
+		if g == nil {
+			continue
+		}
+		key, name := merchant(r)
+		if !r.Time.Before(since.Add(-duplicateWindow)) {
+			recent = append(recent, r)
+		}
+		if r.Time.Before(start) {
+			lookup(groups, g.Name, g.Name).add(r)
+			lookup(merchants, key, name).add(r)
+			continue
+		}
+		lookup(recentGroups, g.Name, g.Name).add(r)
+		lookup(recentMerchants, key, name).add(r)
+	}
+	sort.SliceStable(recent, func(i, k int) bool { return recent[i].Time.Before(recent[k].Time) })
+
+	var anomalies []Anomaly
+	flagged := make(map[string]bool) // IDs of anomalous records
+	flag := func(a Anomaly, r record.Record) {
+		anomalies = append(anomalies, a)
+		flagged[r.ID()] = true
+	}
+	for i, r := range recent {
+		if r.Time.Before(since) {
+			continue // Only considered as the original of a duplicate
+		}
+		g := j.findGroup(r)
+		key, name := merchant(r)
+		var s *series
+		if m, ok := merchants[key]; ok && len(m.amounts) >= minAmountSamples {
+			s = m
+		} else if gs, ok := groups[g.Name]; ok && len(gs.amounts) >= minAmountSamples {
+			s = gs
+		}
+		if s != nil {
+			st := newStats(s.amounts)
+			if score := st.score(r.Amount); score >= threshold {
+				flag(Anomaly{Kind: AmountAnomaly, Group: g.Name, Merchant: name, Time: r.Time, Amount: r.Amount,
+					Median: st.median, Score: score, Records: []record.Record{r}}, r)
+				continue
+			}
+		}
+		if m, ok := merchants[key]; ok && m.monthlyCount() > 1 {
+			continue
+		}
+		for _, prev := range recent[:i] {
+			if prev.Account.Number == r.Account.Number && prev.Amount == r.Amount && r.Time.Sub(prev.Time) <= duplicateWindow {
+				if prevKey, _ := merchant(prev); prevKey == key {
+					flag(Anomaly{Kind: DuplicateAnomaly, Group: g.Name, Merchant: name, Time: r.Time, Amount: r.Amount,
+						Median: prev.Amount, Records: []record.Record{prev, r}}, r)
+					break
+				}
+			}
+		}
+	}
+	groupMonths := make(map[string]map[time.Time]int64) // Sums of anomalous group months
+	monthly := func(current, past map[string]*series, byMerchant bool) {
+		for key, s := range current {
+			h, ok := past[key]
+			if !ok {
+				continue
+			}
+			st, ok := h.monthlyStats(history)
+			if !ok {
+				continue
+			}
+			for m, rs := range s.months {
+				var (
+					kept  []record.Record
+					total int64
+				)
+				for _, r := range rs {
+					if !flagged[r.ID()] {
+						kept = append(kept, r)
+						total += r.Amount
+					}
+				}
+				score := st.score(total)
+				if score < threshold {
+					continue
+				}
+				a := Anomaly{Kind: MonthAnomaly, Group: s.name, Time: m, Amount: total, Median: st.median, Score: score, Records: kept}
+				if byMerchant {
+					a.Merchant = s.name
+					a.Group = j.findGroup(kept[0]).Name
+					if n, ok := groupMonths[a.Group][m]; ok && n == total {
+						continue // The merchant makes up the whole anomalous group month
+					}
+				} else {
+					if groupMonths[a.Group] == nil {
+						groupMonths[a.Group] = make(map[time.Time]int64)
+					}
+					groupMonths[a.Group][m] = total
+				}
+				anomalies = append(anomalies, a)
+			}
+		}
+	}
+	monthly(recentGroups, groups, false)
+	monthly(recentMerchants, merchants, true)
+	sort.SliceStable(anomalies, func(i, k int) bool {
+		a, b := anomalies[i], anomalies[k]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.After(b.Time)
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		return a.Merchant < b.Merchant
+	})
+	return anomalies, nil
+}
@@ -0,0 +1,120 @@
+package journal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestAnomalies(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[groups]]
+name = "Electricity"
+patterns = ["^Power"]
+
+[[groups]]
+name = "Groceries"
+patterns = ["^Groceries"]
+
+[[groups]]
+name = "Spam"
+patterns = ["^Spam"]
+discard = true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rs []record.Record
+	power := []int64{-58000, -60000, -61000, -59000, -60000, -62000}
+	for i, amount := range power {
+		m := time.Month(1 + i)
+		rs = append(rs,
+			record.Record{Time: date(2018, m, 5), Text: "Power company", Amount: amount},
+			record.Record{Time: date(2018, m, 15), Text: "Netflix 123456", Amount: -10000},
+			record.Record{Time: date(2018, m, 10), Text: "Groceries", Amount: -3000},
+			record.Record{Time: date(2018, m, 20), Text: "Groceries", Amount: -3200},
+			record.Record{Time: date(2018, m, 25), Text: "Spam", Amount: -100},
+		)
+	}
+	rs = append(rs,
+		record.Record{Time: date(2018, 7, 5), Text: "Power company", Amount: -120000}, // Doubled bill
+		record.Record{Time: date(2018, 7, 15), Text: "Netflix 123456", Amount: -10000},
+		record.Record{Time: date(2018, 7, 16), Text: "Netflix 654321", Amount: -10000}, // Duplicate charge
+		record.Record{Time: date(2018, 7, 2), Text: "Groceries", Amount: -3100},
+		record.Record{Time: date(2018, 7, 8), Text: "Groceries", Amount: -3000},
+		record.Record{Time: date(2018, 7, 14), Text: "Groceries", Amount: -3300},
+		record.Record{Time: date(2018, 7, 20), Text: "Groceries", Amount: -2900},
+		record.Record{Time: date(2018, 7, 26), Text: "Groceries", Amount: -3200}, // Spending more often
+		record.Record{Time: date(2018, 7, 25), Text: "Spam", Amount: -100000},    // Discarded
+		record.Record{Time: date(2018, 7, 27), Text: "Gym 111111", Amount: -500},
+		record.Record{Time: date(2018, 7, 28), Text: "Gym 222222", Amount: -500}, // Duplicate charge without history
+	)
+	if _, err := j.Write("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		kind     string
+		group    string
+		merchant string
+		time     time.Time
+		amount   int64
+		median   int64
+	}{
+		{DuplicateAnomaly, "* ungrouped *", "Gym", date(2018, 7, 28), -500, -500},
+		{DuplicateAnomaly, "* ungrouped *", "Netflix", date(2018, 7, 16), -10000, -10000},
+		{AmountAnomaly, "Electricity", "Power Company", date(2018, 7, 5), -120000, -60000},
+		{MonthAnomaly, "Groceries", "", date(2018, 7, 1), -15500, -6200},
+	}
+	anomalies, err := j.Anomalies(date(2018, 7, 1), date(2018, 7, 31), 6, 3.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anomalies) != len(tests) {
+		t.Fatalf("want %d anomalies, got %d: %+v", len(tests), len(anomalies), anomalies)
+	}
+	for i, tt := range tests {
+		a := anomalies[i]
+		if a.Kind != tt.kind || a.Group != tt.group || a.Merchant != tt.merchant || !a.Time.Equal(tt.time) || a.Amount != tt.amount || a.Median != tt.median {
+			t.Errorf("#%d: want %s %s/%s at %s: amount = %d, median = %d; got %s %s/%s at %s: amount = %d, median = %d",
+				i, tt.kind, tt.group, tt.merchant, tt.time, tt.amount, tt.median, a.Kind, a.Group, a.Merchant, a.Time, a.Amount, a.Median)
+		}
+	}
+
+	// The original of a duplicate may occur before since
+	anomalies, err = j.Anomalies(date(2018, 7, 16), date(2018, 7, 31), 6, 3.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, a := range anomalies {
+		found = found || (a.Merchant == "Netflix" && a.Kind == DuplicateAnomaly)
+	}
+	if !found {
+		t.Errorf("want duplicate of record before since, got %+v", anomalies)
+	}
+
+	j.Discarding = false
+	anomalies, err = j.Anomalies(date(2018, 7, 1), date(2018, 7, 31), 6, 3.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found = false
+	for _, a := range anomalies {
+		found = found || (a.Group == "Spam" && a.Kind == AmountAnomaly)
+	}
+	if !found {
+		t.Errorf("want anomaly in discarded group when not discarding, got %+v", anomalies)
+	}
+}
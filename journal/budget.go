@@ -0,0 +1,142 @@
+package journal
+
+import (
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+func monthStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+}
+
+// recordInGroup returns whether record r is assorted into group, or any group nested under it.
+func (j *Journal) recordInGroup(r record.Record, group string) bool {
+	g := j.findGroup(r)
+	return g != nil && record.InGroup(g.Name, group)
+}
+
+// HasRollover returns whether any group has rollover enabled.
+func (j *Journal) HasRollover() bool {
+	for _, g := range j.configGroups {
+		if g.Rollover {
+			return true
+		}
+	}
+	return false
+}
+
+// Carry sets the balance carried over into the month of since for groups in gs, including their children, that have
+// rollover enabled. Starting from the first month containing records of a group, each month's surplus or deficit is
+// carried over to the next month. Only records in accountNumbers that are selected by the journal's filter are considered.
+// If accountNumbers is empty, records in all accounts are considered.
+func (j *Journal) Carry(gs []record.Group, accountNumbers []string, since time.Time) error {
+	if !j.HasRollover() {
+		return nil
+	}
+	var rollover []*Group
+	for i := range j.configGroups {
+		if g := &j.configGroups[i]; g.Rollover {
+			rollover = append(rollover, g)
+		}
+	}
+	start := monthStart(since)
+	rs, err := j.ReadAmortized(accountNumbers, time.Time{}, start.Add(-time.Second))
+	if err != nil {
+		return err
+	}
+	rs = j.filter(rs)
+	sums := make(map[string]map[time.Time]int64)
+	first := make(map[string]time.Time)
+	for _, r := range rs {
+		rg := j.findGroup(r)
+		if rg == nil {
+			continue
+		}
+		m := monthStart(r.Time)
+		for _, g := range rollover {
+			if !record.InGroup(rg.Name, g.Name) {
+				continue
+			}
+			if sums[g.Name] == nil {
+				sums[g.Name] = make(map[time.Time]int64)
+			}
+			sums[g.Name][m] += r.Amount
+			if f, ok := first[g.Name]; !ok || m.Before(f) {
+				first[g.Name] = m
+			}
+		}
+	}
+	carried := make(map[string]int64)
+	for _, g := range rollover {
+		f, ok := first[g.Name]
+		if !ok {
+			continue
+		}
+		rg := recordGroup(*g)
+		var carry int64
+		for m := f; m.Before(start); m = m.AddDate(0, 1, 0) {
+			carry += rg.Budget(record.PeriodRange(record.MonthPeriod, m)) - sums[g.Name][m]
+		}
+		carried[g.Name] = carry
+	}
+	var set func(gs []record.Group)
+	set = func(gs []record.Group) {
+		for i := range gs {
+			if carry, ok := carried[gs[i].Name]; ok {
+				gs[i].Carry = carry
+			}
+			set(gs[i].Children)
+		}
+	}
+	set(gs)
+	return nil
+}
+
+// HasBudgetPeriods returns whether any group has a budget applying to another period than a month.
+func (j *Journal) HasBudgetPeriods() bool {
+	for _, g := range j.configGroups {
+		if g.BudgetPeriod != "" && g.BudgetPeriod != record.MonthPeriod {
+			return true
+		}
+	}
+	return false
+}
+
+// Spend sets the amount spent in the budget period containing t for groups in gs, including their children, that have a
+// budget applying to another period than a month. Only records in accountNumbers that are selected by the journal's
+// filter are considered. If accountNumbers is empty, records in all accounts are considered.
+func (j *Journal) Spend(gs []record.Group, accountNumbers []string, t time.Time) error {
+	records := make(map[string][]record.Record)
+	var spend func(gs []record.Group) error
+	spend = func(gs []record.Group) error {
+		for i := range gs {
+			if err := spend(gs[i].Children); err != nil {
+				return err
+			}
+			period := gs[i].BudgetPeriod()
+			if period == record.MonthPeriod || !gs[i].HasBudget() {
+				continue
+			}
+			rs, ok := records[period]
+			if !ok {
+				r := record.PeriodRange(period, t)
+				var err error
+				if rs, err = j.ReadAmortized(accountNumbers, r.Since, r.Until); err != nil {
+					return err
+				}
+				rs = j.filter(rs)
+				records[period] = rs
+			}
+			var spent int64
+			for _, r := range rs {
+				if j.recordInGroup(r, gs[i].Name) {
+					spent += r.Amount
+				}
+			}
+			gs[i].Spent = spent
+		}
+		return nil
+	}
+	return spend(gs)
+}
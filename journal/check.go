@@ -0,0 +1,167 @@
+package journal
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/BurntSushi/toml"
+)
+
+// A ConfigProblem is a problem found in a configuration file.
+type ConfigProblem struct {
+	Source  string
+	Message string
+	fatal   bool
+}
+
+func (p ConfigProblem) Error() string {
+	if p.Source == "" {
+		return p.Message
+	}
+	return p.Source + ": " + p.Message
+}
+
+// CheckConfig reads configuration file name, including any files it includes, and returns all problems found in them.
+// An error is returned if the file cannot be read.
+func CheckConfig(name string) ([]ConfigProblem, error) {
+	r := configReader{check: true}
+	conf, ok, err := r.read(configPath(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	problems := r.problems
+	if ok {
+		problems = append(problems, conf.validate()...)
+	}
+	return problems, nil
+}
+
+// checkKeys reports the keys in undecoded as unknown, at every line of data where they're declared.
+func (r *configReader) checkKeys(name, data string, undecoded []toml.Key) {
+	unknown := make(map[string]bool)
+	for _, k := range undecoded {
+		unknown[strings.ToLower(k.String())] = true
+	}
+	table := ""
+	for i, line := range strings.Split(data, "\n") {
+		if m := tableHeader.FindStringSubmatch(line); m != nil {
+			table = m[1]
+			if unknown[strings.ToLower(table)] && !unknown[strings.ToLower(parentKey(table))] {
+				r.unknownKey(position{file: name, line: i + 1}, table)
+			}
+			continue
+		}
+		m := keyValue.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := m[1]
+		if table != "" {
+			key = table + "." + key
+		}
+		if unknown[strings.ToLower(key)] && !unknown[strings.ToLower(table)] {
+			r.unknownKey(position{file: name, line: i + 1}, key)
+		}
+	}
+}
+
+func (r *configReader) unknownKey(source position, key string) {
+	msg := fmt.Sprintf("unknown key: %q", key)
+	parts := strings.Split(key, ".")
+	if s := suggestKey(parts[len(parts)-1], configKeys(parts[:len(parts)-1])); s != "" {
+		msg += fmt.Sprintf(", did you mean %q?", s)
+	}
+	r.problems = append(r.problems, ConfigProblem{Source: source.String(), Message: msg})
+}
+
+func parentKey(key string) string {
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+// configKeys returns the keys accepted in the configuration table at path.
+func configKeys(path []string) []string {
+	t := reflect.TypeOf(Config{})
+	for _, k := range path {
+		f, ok := exportedField(t, k)
+		if !ok {
+			return nil
+		}
+		t = f.Type
+		for t.Kind() == reflect.Slice || t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return nil
+		}
+	}
+	var keys []string
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() {
+			keys = append(keys, tomlKey(f.Name))
+		}
+	}
+	return keys
+}
+
+func exportedField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.IsExported() && strings.EqualFold(f.Name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// tomlKey returns the conventional configuration key of field name, e.g. "openingBalance" for OpeningBalance.
+func tomlKey(name string) string {
+	rs := []rune(name)
+	upper := 0
+	for upper < len(rs) && unicode.IsUpper(rs[upper]) {
+		upper++
+	}
+	// Lowercase an initialism entirely, including its plural, e.g. "ids" for IDs
+	if upper > 1 && upper < len(rs) && string(rs[upper:]) != "s" {
+		upper--
+	}
+	for i := 0; i < upper || i == 0; i++ {
+		rs[i] = unicode.ToLower(rs[i])
+	}
+	return string(rs)
+}
+
+// suggestKey returns the key in keys closest to key, if any is close enough to be a likely misspelling.
+func suggestKey(key string, keys []string) string {
+	best, bestDistance := "", len(key)/2+1
+	for _, k := range keys {
+		if d := distance(strings.ToLower(key), strings.ToLower(k)); d < bestDistance {
+			best, bestDistance = k, d
+		}
+	}
+	return best
+}
+
+// distance returns the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,114 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckConfig(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"journalrc": `Database = ":memory:"
+include = ["groups.toml", "missing.toml"]
+defaultGropu = "* none *"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1 (again)"
+type = "chequing"
+
+[[accountGroups]]
+name = "household"
+accounts = ["1234.56.78900", "1234.56.78901", "1234.56.78902"]
+`,
+		"groups.toml": `[[groups]]
+name = "Unimportant"
+pattern = ["^Spam"]
+discard = true
+
+[[groups]]
+name = "Groceries"
+patterns = ["^Rema", "(", "^Kiwi", "[a"]
+budgets = [1, 2, 3]
+account = "1234.56.78909"
+
+  [[groups.rules]]
+  pattern = "^Coop"
+  sgin = "expense"
+
+  [[groups.rules]]
+  sign = "both"
+`,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	main := filepath.Join(dir, "journalrc")
+	groups := filepath.Join(dir, "groups.toml")
+	problems, err := CheckConfig(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, p.Error())
+	}
+	want := []string{
+		main + `:3: unknown key: "defaultGropu", did you mean "defaultGroup"?`,
+		groups + `:3: unknown key: "groups.pattern", did you mean "patterns"?`,
+		groups + `:14: unknown key: "groups.rules.sgin", did you mean "sign"?`,
+		main + `: included file does not exist: ` + filepath.Join(dir, "missing.toml"),
+		main + `:9: account: "1234.56.78900": invalid type: "chequing"`,
+		main + `:9: account: "1234.56.78900": already declared at ` + main + `:5`,
+		main + `:14: account group: "household": invalid account: "1234.56.78901"`,
+		main + `:14: account group: "household": invalid account: "1234.56.78902"`,
+		groups + `:6: group: "Groceries": budgets must have 12 values, got 3`,
+		groups + ":6: group: \"Groceries\": patterns[1]: error parsing regexp: missing closing ): `(`",
+		groups + ":6: group: \"Groceries\": patterns[3]: error parsing regexp: missing closing ]: `[a`",
+		groups + `:6: group: "Groceries": rules[1]: invalid sign: "both"`,
+		groups + `:6: group: "Groceries": account is not declared: "1234.56.78909"`,
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want problems\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	// Unparsable files are reported without further checks
+	if err := os.WriteFile(groups, []byte("[[groups]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	problems, err = CheckConfig(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) < 2 || !strings.HasPrefix(problems[1].Error(), groups+": toml: line") {
+		t.Errorf("want parse error, got %+v", problems)
+	}
+	if _, err := CheckConfig(filepath.Join(dir, "nonexistent")); err == nil {
+		t.Error("want error for missing config file")
+	}
+}
+
+func TestTomlKey(t *testing.T) {
+	var tests = []struct {
+		in, out string
+	}{
+		{"Name", "name"},
+		{"IDs", "ids"},
+		{"OpeningBalance", "openingBalance"},
+		{"DaysOfMonth", "daysOfMonth"},
+	}
+	for i, tt := range tests {
+		if got := tomlKey(tt.in); got != tt.out {
+			t.Errorf("#%d: want %q, got %q", i, tt.out, got)
+		}
+	}
+}
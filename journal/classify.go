@@ -0,0 +1,169 @@
+package journal
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+// A Classification is a group proposed by the classifier for a record.
+type Classification struct {
+	Record     record.Record
+	Group      string
+	Confidence float64
+}
+
+// classifier implements a multinomial naive Bayes classifier of records.
+type classifier struct {
+	docs     int
+	groups   map[string]int
+	counts   map[string]map[string]int
+	totals   map[string]int
+	features map[string]bool
+}
+
+func newClassifier() *classifier {
+	return &classifier{
+		groups:   make(map[string]int),
+		counts:   make(map[string]map[string]int),
+		totals:   make(map[string]int),
+		features: make(map[string]bool),
+	}
+}
+
+// recordFeatures returns the features of record r: the words of its text, the magnitude of its amount and its account.
+func recordFeatures(r record.Record) []string {
+	var fs []string
+	for _, t := range tokens(r.Text) {
+		if isNumber(t) {
+			t = "#"
+		}
+		fs = append(fs, "word:"+t)
+	}
+	sign := "+"
+	if r.Amount < 0 {
+		sign = "-"
+	}
+	magnitude := len(strconv.FormatInt(abs(r.Amount)/100, 10))
+	fs = append(fs, "amount:"+sign+strconv.Itoa(magnitude), "account:"+r.Account.Number)
+	return fs
+}
+
+func (c *classifier) train(r record.Record, group string) {
+	c.docs++
+	c.groups[group]++
+	if c.counts[group] == nil {
+		c.counts[group] = make(map[string]int)
+	}
+	for _, f := range recordFeatures(r) {
+		c.counts[group][f]++
+		c.totals[group]++
+		c.features[f] = true
+	}
+}
+
+// classify returns the most probable group of record r, and its probability relative to the other groups.
+func (c *classifier) classify(r record.Record) (string, float64) {
+	if c.docs == 0 {
+		return "", 0
+	}
+	fs := recordFeatures(r)
+	groups := make([]string, 0, len(c.groups))
+	for g := range c.groups {
+		groups = append(groups, g)
+	}
+	sort.Strings(groups)
+	logProbs := make([]float64, len(groups))
+	vocabulary := float64(len(c.features))
+	best := 0
+	for i, g := range groups {
+		p := math.Log(float64(c.groups[g]) / float64(c.docs))
+		for _, f := range fs {
+			// Laplace smoothing
+			p += math.Log((float64(c.counts[g][f]) + 1) / (float64(c.totals[g]) + vocabulary))
+		}
+		logProbs[i] = p
+		if p > logProbs[best] {
+			best = i
+		}
+	}
+	var sum float64
+	for _, p := range logProbs {
+		sum += math.Exp(p - logProbs[best])
+	}
+	return groups[best], 1 / sum
+}
+
+// trainClassifier trains a classifier on all records in the journal that are matched by a pin or rule. Records
+// belonging to discarded groups are not used for training.
+func (j *Journal) trainClassifier() (*classifier, error) {
+	rs, err := j.Read(nil, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	c := newClassifier()
+	for _, r := range rs {
+		g := j.matchGroup(r)
+		if g == nil || g.Discard {
+			continue
+		}
+		c.train(r, g.Name)
+	}
+	return c, nil
+}
+
+// Classify proposes groups for the records in records that are not matched by any pin or rule, using a classifier
+// trained on the matched records in the journal. Classifications are ordered by descending confidence.
+func (j *Journal) Classify(records []record.Record) ([]Classification, error) {
+	c := j.classifier
+	if c == nil {
+		var err error
+		if c, err = j.trainClassifier(); err != nil {
+			return nil, err
+		}
+	}
+	var cs []Classification
+	for _, r := range records {
+		if j.matchGroup(r) != nil {
+			continue
+		}
+		group, confidence := c.classify(r)
+		if group == "" {
+			continue
+		}
+		cs = append(cs, Classification{Record: r, Group: group, Confidence: confidence})
+	}
+	sort.SliceStable(cs, func(i, j int) bool { return cs[i].Confidence > cs[j].Confidence })
+	return cs, nil
+}
+
+// classify returns a match for the group proposed by the classifier of this journal, if the classifier is enabled and
+// confident enough. The classifier is trained on first use. If training fails, no records are classified.
+func (j *Journal) classify(r record.Record) (Match, *Group, bool) {
+	if j.classifying && j.classifier == nil {
+		j.classifying = false // Train only once
+		j.classifier, _ = j.trainClassifier()
+	}
+	if j.classifier == nil {
+		return Match{}, nil, false
+	}
+	name, confidence := j.classifier.classify(r.Original())
+	if name == "" || confidence < j.classifyThreshold {
+		return Match{}, nil, false
+	}
+	g := j.configGroup(name)
+	if g == nil {
+		return Match{}, nil, false
+	}
+	return Match{
+		Group:      g.Name,
+		Rule:       fmt.Sprintf("classified (%.0f%%)", confidence*100),
+		Matched:    true,
+		Classified: true,
+		Discard:    g.Discard,
+	}, g, true
+}
@@ -0,0 +1,98 @@
+package journal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestClassify(t *testing.T) {
+	j := testJournal(t)
+	a := record.Account{Number: "1234.56.78900"}
+	rs := []record.Record{
+		{Account: a, Time: date(2018, 1, 1), Text: "Foo Airline Oslo", Amount: -250000},     // Travel
+		{Account: a, Time: date(2018, 1, 2), Text: "Foo Hotel Oslo", Amount: -120000},       // Travel
+		{Account: a, Time: date(2018, 1, 3), Text: "Foo Taxi Oslo", Amount: -40000},         // Travel
+		{Account: a, Time: date(2018, 1, 4), Text: "Bar Rema 1000", Amount: -3500},          // Groceries
+		{Account: a, Time: date(2018, 1, 5), Text: "Bar Rema 1000 Lade", Amount: -4200},     // Groceries
+		{Account: a, Time: date(2018, 1, 6), Text: "Baz Kiwi Rema", Amount: -2900},          // Groceries
+		{Account: a, Time: date(2018, 1, 7), Text: "Spam Rema Rema Rema", Amount: -1500},    // Discarded, not used for training
+		{Account: a, Time: date(2018, 1, 8), Text: "Airline tickets Oslo", Amount: -300000}, // Ungrouped
+		{Account: a, Time: date(2018, 1, 9), Text: "Rema 1000 Moholt", Amount: -3100},       // Ungrouped
+	}
+	if _, err := j.Write(a.Number, rs); err != nil {
+		t.Fatal(err)
+	}
+
+	cs, err := j.Classify(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		text  string
+		group string
+	}{
+		{"Rema 1000 Moholt", "Groceries"},
+		{"Airline tickets Oslo", "Travel"},
+	}
+	if want, got := len(tests), len(cs); want != got {
+		t.Fatalf("want %d classifications, got %d: %+v", want, got, cs)
+	}
+	for i, tt := range tests {
+		c := cs[i]
+		if c.Record.Text != tt.text {
+			t.Errorf("#%d: want Text = %q, got %q", i, tt.text, c.Record.Text)
+		}
+		if c.Group != tt.group {
+			t.Errorf("#%d: want Group = %q, got %q", i, tt.group, c.Group)
+		}
+		if c.Confidence < 0.5 || c.Confidence > 1 {
+			t.Errorf("#%d: want Confidence in [0.5, 1], got %f", i, c.Confidence)
+		}
+	}
+
+	// Classifier is only used for grouping when enabled
+	r := rs[8]
+	if got := j.findGroup(r).Name; got != j.DefaultGroup {
+		t.Errorf("want group %q, got %q", j.DefaultGroup, got)
+	}
+	j.classifying = true // Trained on first use
+	j.classifyThreshold = 0.5
+	if got := j.findGroup(r).Name; got != "Groceries" {
+		t.Errorf("want group %q, got %q", "Groceries", got)
+	}
+	m, ok := j.MatchOf(r)
+	if !ok || !m.Classified || m.Group != "Groceries" {
+		t.Errorf("want classified match of group %q, got %+v", "Groceries", m)
+	}
+	// Rules take precedence over the classifier
+	if got := j.findGroup(rs[0]).Name; got != "Travel" {
+		t.Errorf("want group %q, got %q", "Travel", got)
+	}
+	j.classifyThreshold = 1
+	if got := j.findGroup(r).Name; got != j.DefaultGroup {
+		t.Errorf("want group %q, got %q", j.DefaultGroup, got)
+	}
+}
+
+func TestClassifyConfig(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+classify = true
+classifyThreshold = 0.0
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.classifier != nil {
+		t.Error("want classifier to be trained on first use")
+	}
+	if j.classifyThreshold != 0 {
+		t.Errorf("want threshold 0, got %f", j.classifyThreshold)
+	}
+}
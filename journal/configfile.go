@@ -0,0 +1,283 @@
+package journal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mpolden/journal/record"
+)
+
+var (
+	tableHeader = regexp.MustCompile(`^\s*\[\[?\s*([^\]\s]+)\s*\]\]?\s*(#.*)?$`)
+	keyValue    = regexp.MustCompile(`^\s*([A-Za-z0-9_-]+)\s*=`)
+)
+
+// A ConfigFile is a configuration file that can be edited without losing its comments and formatting.
+type ConfigFile struct {
+	path     string
+	mode     os.FileMode
+	lines    []string
+	included map[string]*ConfigFile
+}
+
+// a groupTable is the location of a [[groups]] table in a configuration file.
+type groupTable struct {
+	name   string
+	parent string
+	start  int // Line of the table header
+	end    int // Line following the last line of the table, including any sub-tables
+	sub    bool
+}
+
+// groupName returns the name of a group with given name and parent, as it is loaded from a configuration file. A name
+// that is already qualified by its parent is returned unchanged.
+func groupName(name, parent string) string {
+	if parent == "" || strings.HasPrefix(name, parent+record.Separator) {
+		return name
+	}
+	return parent + record.Separator + name
+}
+
+// ReadConfigFile reads the configuration file at path for editing.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+	path = configPath(path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &ConfigFile{path: path, mode: fi.Mode(), lines: strings.Split(string(data), "\n")}, nil
+}
+
+// String returns the current contents of this configuration file.
+func (c *ConfigFile) String() string { return strings.Join(c.lines, "\n") }
+
+// Pin adds record ID id to the ids of group. If group is declared in an included file, that file is edited instead.
+// The group is appended to the file if it does not exist.
+func (c *ConfigFile) Pin(group, id string) error { return c.addValue(group, "ids", id) }
+
+// AddPattern adds pattern to the patterns of group. If group is declared in an included file, that file is edited
+// instead. The group is appended to the file if it does not exist.
+func (c *ConfigFile) AddPattern(group, pattern string) error {
+	if _, err := regexp.Compile(pattern); err != nil {
+		return err
+	}
+	return c.addValue(group, "patterns", pattern)
+}
+
+// Save writes this configuration file, and any edited included files, to disk. Files are only written if their contents
+// are still a valid configuration.
+func (c *ConfigFile) Save() error {
+	files := []*ConfigFile{c}
+	for _, inc := range c.included {
+		files = append(files, inc)
+	}
+	for _, f := range files {
+		var conf Config
+		if _, err := toml.Decode(f.String(), &conf); err != nil {
+			return fmt.Errorf("%s: edit resulted in invalid config: %w", f.path, err)
+		}
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.path, []byte(f.String()), f.mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// includedFile returns the included file declaring group, if any.
+func (c *ConfigFile) includedFile(group string) (*ConfigFile, error) {
+	conf, err := readConfigFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range conf.Groups {
+		if groupName(g.Name, g.Parent) != group {
+			continue
+		}
+		path := g.source.file
+		if path == c.path {
+			return nil, nil
+		}
+		if inc, ok := c.included[path]; ok {
+			return inc, nil
+		}
+		inc, err := ReadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if c.included == nil {
+			c.included = make(map[string]*ConfigFile)
+		}
+		c.included[path] = inc
+		return inc, nil
+	}
+	return nil, nil
+}
+
+func (c *ConfigFile) groupTables() []groupTable {
+	var tables []groupTable
+	current := -1
+	for i, line := range c.lines {
+		if m := tableHeader.FindStringSubmatch(line); m != nil {
+			name := m[1]
+			if name == "groups" && strings.HasPrefix(strings.TrimSpace(line), "[[") {
+				tables = append(tables, groupTable{start: i, end: len(c.lines)})
+				current = len(tables) - 1
+			} else if current >= 0 && strings.HasPrefix(name, "groups.") {
+				tables[current].sub = true // Keys following a sub-table belong to the sub-table
+			} else if current >= 0 {
+				tables[current].end = i
+				current = -1
+			}
+			continue
+		}
+		if current < 0 || tables[current].sub {
+			continue
+		}
+		if m := keyValue.FindStringSubmatch(line); m != nil {
+			var v struct{ Name, Parent string }
+			if _, err := toml.Decode(line, &v); err != nil {
+				continue
+			}
+			switch {
+			case strings.EqualFold(m[1], "name"):
+				tables[current].name = v.Name
+			case strings.EqualFold(m[1], "parent"):
+				tables[current].parent = v.Parent
+			}
+		}
+	}
+	return tables
+}
+
+func (c *ConfigFile) addValue(group, key, value string) error {
+	quoted := strconv.Quote(value)
+	for _, t := range c.groupTables() {
+		if groupName(t.name, t.parent) != group {
+			continue
+		}
+		nameLine := -1
+		for i := t.start + 1; i < t.end; i++ {
+			if tableHeader.MatchString(c.lines[i]) {
+				break // Keys following a sub-table belong to the sub-table
+			}
+			m := keyValue.FindStringSubmatch(c.lines[i])
+			if m == nil {
+				continue
+			}
+			if strings.EqualFold(m[1], "name") {
+				nameLine = i
+			}
+			if strings.EqualFold(m[1], key) {
+				return c.appendArray(i, quoted)
+			}
+		}
+		if nameLine < 0 {
+			return fmt.Errorf("%s: group %q has no name key", c.path, group)
+		}
+		indent := c.lines[nameLine][:len(c.lines[nameLine])-len(strings.TrimLeft(c.lines[nameLine], " \t"))]
+		c.insertLines(nameLine+1, indent+key+" = ["+quoted+"]")
+		return nil
+	}
+	inc, err := c.includedFile(group)
+	if err != nil {
+		return err
+	}
+	if inc != nil {
+		return inc.addValue(group, key, value)
+	}
+	var conf Config
+	if _, err := toml.Decode(c.String(), &conf); err != nil {
+		return fmt.Errorf("%s: %w", c.path, err)
+	}
+	for _, g := range conf.Groups {
+		if groupName(g.Name, g.Parent) == group {
+			return fmt.Errorf("%s: group %q is not declared as a [[groups]] table", c.path, group)
+		}
+	}
+	// Trim trailing blank lines before appending a new group
+	for len(c.lines) > 0 && strings.TrimSpace(c.lines[len(c.lines)-1]) == "" {
+		c.lines = c.lines[:len(c.lines)-1]
+	}
+	c.lines = append(c.lines, "", "[[groups]]", "name = "+strconv.Quote(group), key+" = ["+quoted+"]", "")
+	return nil
+}
+
+func (c *ConfigFile) insertLines(at int, lines ...string) {
+	c.lines = append(c.lines[:at], append(lines, c.lines[at:]...)...)
+}
+
+// appendArray appends quoted to the array value of the key declared on line n.
+func (c *ConfigFile) appendArray(n int, quoted string) error {
+	text := strings.Join(c.lines[n:], "\n")
+	start := strings.Index(text, "=") + 1
+	for start < len(text) && (text[start] == ' ' || text[start] == '\t') {
+		start++
+	}
+	if start >= len(text) || text[start] != '[' {
+		return fmt.Errorf("%s:%d: expected array", c.path, n+1)
+	}
+	depth := 0
+	lastValue := -1 // Offset following the last value in the array
+	end := -1
+	for i := start; i < len(text) && end < 0; i++ {
+		switch ch := text[i]; ch {
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				end = i
+			}
+		case '#':
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case '"', '\'':
+			multi := strings.HasPrefix(text[i:], strings.Repeat(string(ch), 3))
+			delim := string(ch)
+			if multi {
+				delim = strings.Repeat(delim, 3)
+			}
+			j := i + len(delim)
+			for j < len(text) && !strings.HasPrefix(text[j:], delim) {
+				if ch == '"' && text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(text) {
+				return fmt.Errorf("%s:%d: unterminated string", c.path, n+1)
+			}
+			i = j + len(delim) - 1
+			lastValue = i + 1
+		}
+	}
+	if end < 0 {
+		return fmt.Errorf("%s:%d: unterminated array", c.path, n+1)
+	}
+	var edited string
+	switch {
+	case lastValue < 0:
+		edited = text[:end] + quoted + text[end:]
+	case strings.Contains(text[lastValue:end], "\n"):
+		// Multi-line array: add the value on a new line, with the same indentation as the last value
+		lineStart := strings.LastIndex(text[:lastValue], "\n") + 1
+		line := text[lineStart:]
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		edited = text[:lastValue] + ",\n" + indent + quoted + text[lastValue:]
+	default:
+		edited = text[:lastValue] + ", " + quoted + text[lastValue:]
+	}
+	c.lines = append(c.lines[:n], strings.Split(edited, "\n")...)
+	return nil
+}
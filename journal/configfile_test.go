@@ -0,0 +1,240 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigFile(t *testing.T) {
+	conf := `# My journal
+Database = "~/.journal.db"
+
+[[groups]]
+name = "Groceries" # Food and such
+patterns = [
+  "^Rema", # Rema 1000
+  "^Kiwi",
+]
+
+  [[groups.rules]]
+  pattern = "^Bunnpris"
+  name = "not a group"
+
+[[groups]]
+name = "Travel"
+patterns = ["^Foo", '^Bar\d']
+ids = []
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+`
+	var tests = []struct {
+		edit func(*ConfigFile) error
+		want string
+	}{
+		{func(c *ConfigFile) error { return c.AddPattern("Groceries", "^Coop") }, `# My journal
+Database = "~/.journal.db"
+
+[[groups]]
+name = "Groceries" # Food and such
+patterns = [
+  "^Rema", # Rema 1000
+  "^Kiwi",
+  "^Coop",
+]
+
+  [[groups.rules]]
+  pattern = "^Bunnpris"
+  name = "not a group"
+
+[[groups]]
+name = "Travel"
+patterns = ["^Foo", '^Bar\d']
+ids = []
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+`},
+		{func(c *ConfigFile) error { return c.Pin("Groceries", "45defdf469") }, `# My journal
+Database = "~/.journal.db"
+
+[[groups]]
+name = "Groceries" # Food and such
+ids = ["45defdf469"]
+patterns = [
+  "^Rema", # Rema 1000
+  "^Kiwi",
+]
+
+  [[groups.rules]]
+  pattern = "^Bunnpris"
+  name = "not a group"
+
+[[groups]]
+name = "Travel"
+patterns = ["^Foo", '^Bar\d']
+ids = []
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+`},
+		{func(c *ConfigFile) error {
+			if err := c.AddPattern("Travel", `^Baz\s`); err != nil {
+				return err
+			}
+			if err := c.Pin("Travel", "45defdf469"); err != nil {
+				return err
+			}
+			return c.Pin("Travel", "b6b2496771")
+		}, `# My journal
+Database = "~/.journal.db"
+
+[[groups]]
+name = "Groceries" # Food and such
+patterns = [
+  "^Rema", # Rema 1000
+  "^Kiwi",
+]
+
+  [[groups.rules]]
+  pattern = "^Bunnpris"
+  name = "not a group"
+
+[[groups]]
+name = "Travel"
+patterns = ["^Foo", '^Bar\d', "^Baz\\s"]
+ids = ["45defdf469", "b6b2496771"]
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+`},
+		{func(c *ConfigFile) error { return c.Pin("Bunnpris", "45defdf469") }, `# My journal
+Database = "~/.journal.db"
+
+[[groups]]
+name = "Groceries" # Food and such
+patterns = [
+  "^Rema", # Rema 1000
+  "^Kiwi",
+]
+
+  [[groups.rules]]
+  pattern = "^Bunnpris"
+  name = "not a group"
+
+[[groups]]
+name = "Travel"
+patterns = ["^Foo", '^Bar\d']
+ids = []
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[groups]]
+name = "Bunnpris"
+ids = ["45defdf469"]
+`},
+	}
+	dir := t.TempDir()
+	for i, tt := range tests {
+		name := filepath.Join(dir, "journalrc")
+		if err := os.WriteFile(name, []byte(conf), 0600); err != nil {
+			t.Fatal(err)
+		}
+		c, err := ReadConfigFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tt.edit(c); err != nil {
+			t.Fatalf("#%d: %s", i, err)
+		}
+		if err := c.Save(); err != nil {
+			t.Fatalf("#%d: %s", i, err)
+		}
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("#%d: want\n%s\ngot\n%s", i, tt.want, got)
+		}
+	}
+
+	c, err := ReadConfigFile(filepath.Join(dir, "journalrc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddPattern("Travel", "("); err == nil {
+		t.Error("want error for invalid pattern")
+	}
+}
+
+func TestConfigFileInclude(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "journalrc")
+	groups := filepath.Join(dir, "groups.toml")
+	if err := os.WriteFile(main, []byte("Database = \":memory:\"\ninclude = [\"groups.toml\"]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(groups, []byte("# Groups\n[[groups]]\nname = \"Travel\"\npatterns = [\"^Foo\"]\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadConfigFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddPattern("Travel", "^Bar"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		file string
+		want string
+	}{
+		{main, "Database = \":memory:\"\ninclude = [\"groups.toml\"]\n"},
+		{groups, "# Groups\n[[groups]]\nname = \"Travel\"\npatterns = [\"^Foo\", \"^Bar\"]\n"},
+	}
+	for i, tt := range tests {
+		got, err := os.ReadFile(tt.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("#%d: want\n%s\ngot\n%s", i, tt.want, got)
+		}
+	}
+}
+
+func TestConfigFileParent(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "journalrc")
+	conf := "[[groups]]\nname = \"Food\"\n\n[[groups]]\nname = \"Groceries\"\nparent = \"Food\"\npatterns = [\"^Rema\"]\n"
+	if err := os.WriteFile(name, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c, err := ReadConfigFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddPattern("Food/Groceries", "^Kiwi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Save(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[groups]]\nname = \"Food\"\n\n[[groups]]\nname = \"Groceries\"\nparent = \"Food\"\npatterns = [\"^Rema\", \"^Kiwi\"]\n"
+	if string(got) != want {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+}
@@ -0,0 +1,112 @@
+package journal
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mpolden/journal/record"
+	"github.com/mpolden/journal/sql"
+)
+
+// An Envelope is a group in envelope budgeting. Money is allocated to an envelope each month, and records of the group
+// are spent from it.
+type Envelope struct {
+	Group     string
+	Records   int
+	Allocated int64 // Amount allocated in the range
+	Spent     int64 // Sum of records in the range
+	Carried   int64 // Balance carried over from before the range
+}
+
+// An EnvelopeReport summarizes envelope budgeting in a time range.
+type EnvelopeReport struct {
+	Envelopes []Envelope
+	Income    int64 // Income received until the end of the range
+	Allocated int64 // Amount allocated until the end of the range
+}
+
+// Available returns the amount available for spending in this envelope.
+func (e *Envelope) Available() int64 { return e.Carried + e.Allocated + e.Spent }
+
+// ToBeBudgeted returns the amount of income that has not yet been allocated to any envelope. A negative amount means
+// that more has been allocated than received.
+func (r *EnvelopeReport) ToBeBudgeted() int64 { return r.Income - r.Allocated }
+
+// Envelope returns whether envelope budgeting is enabled.
+func (j *Journal) Envelope() bool { return j.envelope }
+
+// Allocate allocates amount to group in the month containing t, replacing any earlier allocation to group in the same
+// month. An amount of zero removes the allocation.
+func (j *Journal) Allocate(t time.Time, group string, amount int64) error {
+	g := j.configGroup(group)
+	if g == nil {
+		return fmt.Errorf("group %q does not exist", group)
+	}
+	if g.Income {
+		return fmt.Errorf("group %q is an income group and cannot be allocated to", group)
+	}
+	return j.db.SetAllocation(sql.Allocation{Month: monthStart(t).Unix(), Group: group, Amount: amount})
+}
+
+// Envelopes returns the envelopes having records or allocations between since and until, or a balance carried over
+// from before since. The carried balance is computed from the records before since, so records should include all
+// records to consider until the end of the range. Records of groups marked as income are counted as income instead of
+// being spent from an envelope.
+func (j *Journal) Envelopes(records []record.Record, since, until time.Time) (EnvelopeReport, error) {
+	var report EnvelopeReport
+	allocations, err := j.db.SelectAllocations()
+	if err != nil {
+		return report, err
+	}
+	envelopes := make(map[string]*Envelope)
+	envelope := func(name string) *Envelope {
+		e, ok := envelopes[name]
+		if !ok {
+			e = &Envelope{Group: name}
+			envelopes[name] = e
+		}
+		return e
+	}
+	for _, r := range records {
+		if r.Time.After(until) {
+			continue
+		}
+		rg := j.findGroup(r)
+		if rg == nil {
+			continue
+		}
+		if g := j.configGroup(rg.Name); g != nil && g.Income {
+			report.Income += r.Amount
+			continue
+		}
+		e := envelope(rg.Name)
+		if r.Time.Before(since) {
+			e.Carried += r.Amount
+		} else {
+			e.Records++
+			e.Spent += r.Amount
+		}
+	}
+	start := monthStart(since)
+	for _, a := range allocations {
+		month := time.Unix(a.Month, 0).UTC()
+		if month.After(until) {
+			continue
+		}
+		report.Allocated += a.Amount
+		e := envelope(a.Group)
+		if month.Before(start) {
+			e.Carried += a.Amount
+		} else {
+			e.Allocated += a.Amount
+		}
+	}
+	for _, e := range envelopes {
+		if e.Records > 0 || e.Allocated != 0 || e.Available() != 0 {
+			report.Envelopes = append(report.Envelopes, *e)
+		}
+	}
+	sort.Slice(report.Envelopes, func(i, j int) bool { return report.Envelopes[i].Group < report.Envelopes[j].Group })
+	return report, nil
+}
@@ -0,0 +1,101 @@
+package journal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestEnvelopes(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+envelope = true
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[groups]]
+name = "Salary"
+patterns = ["^Salary"]
+income = true
+
+[[groups]]
+name = "Groceries"
+patterns = ["^Food"]
+
+[[groups]]
+name = "Travel"
+patterns = ["^Flight"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !j.Envelope() {
+		t.Fatal("want envelope budgeting")
+	}
+	rs := []record.Record{
+		{Time: date(2018, 1, 1), Text: "Salary", Amount: 10000},
+		{Time: date(2018, 1, 10), Text: "Food 1", Amount: -3000},
+		{Time: date(2018, 2, 10), Text: "Food 2", Amount: -1000},
+		{Time: date(2018, 2, 20), Text: "Food 3", Amount: -500},
+		{Time: date(2018, 3, 1), Text: "Salary", Amount: 10000},
+	}
+	if _, err := j.Write("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	allocations := []struct {
+		month  int
+		group  string
+		amount int64
+	}{
+		{1, "Groceries", 4000},
+		{2, "Groceries", 2000},
+		{2, "Travel", 5000},
+		{3, "Travel", 9000},
+	}
+	for _, a := range allocations {
+		if err := j.Allocate(date(2018, 1, 1).AddDate(0, a.month-1, 0), a.group, a.amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := j.Allocate(date(2018, 1, 1), "Salary", 100); err == nil {
+		t.Error("want error when allocating to income group")
+	}
+	if err := j.Allocate(date(2018, 1, 1), "Foo", 100); err == nil {
+		t.Error("want error when allocating to unknown group")
+	}
+
+	history, err := j.Read(nil, time.Time{}, date(2018, 2, 28))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := j.Envelopes(history, date(2018, 2, 1), date(2018, 2, 28))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := EnvelopeReport{
+		Envelopes: []Envelope{
+			{Group: "Groceries", Records: 2, Allocated: 2000, Spent: -1500, Carried: 1000},
+			{Group: "Travel", Allocated: 5000},
+		},
+		Income:    10000,
+		Allocated: 11000,
+	}
+	if !reflect.DeepEqual(want, report) {
+		t.Errorf("want %+v, got %+v", want, report)
+	}
+	if got := report.ToBeBudgeted(); got != -1000 {
+		t.Errorf("want %d to be budgeted, got %d", -1000, got)
+	}
+	if got := report.Envelopes[0].Available(); got != 1500 {
+		t.Errorf("want %d available, got %d", 1500, got)
+	}
+}
@@ -0,0 +1,253 @@
+package journal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+// Forecast configures cash-flow forecasts.
+type Forecast struct {
+	Account string // Account receiving budgets of groups that are not tied to an account. Defaults to the first account
+}
+
+// A RecurringItem is a payment or income occurring at a regular interval, such as a salary or a rent.
+type RecurringItem struct {
+	Name    string
+	Account string
+	Amount  int64
+	Cadence string // One of weekly, monthly or yearly
+	Date    string // Date of any occurrence, from which other occurrences are projected
+	cadence cadence
+	date    time.Time
+}
+
+// An AccountForecast is the projected balance of an account.
+type AccountForecast struct {
+	Account  record.Account
+	Floor    int64 // The balance should stay above this amount
+	Warn     bool  // Whether to warn when the balance falls below the floor
+	Start    time.Time
+	Balances []int64 // Balance at the end of each day, starting with the day of Start
+}
+
+func (r *RecurringItem) load() error {
+	if r.Name == "" {
+		return fmt.Errorf("invalid recurring name: %q", r.Name)
+	}
+	if r.Account == "" {
+		return fmt.Errorf("recurring: %q: account must be set", r.Name)
+	}
+	if r.Amount == 0 {
+		return fmt.Errorf("recurring: %q: amount must be non-zero", r.Name)
+	}
+	var ok bool
+	if r.cadence, ok = cadenceOf(r.Cadence); !ok {
+		return fmt.Errorf("recurring: %q: invalid cadence: %q", r.Name, r.Cadence)
+	}
+	var err error
+	if r.date, err = time.Parse("2006-01-02", r.Date); err != nil {
+		return fmt.Errorf("recurring: %q: invalid date: %q", r.Name, r.Date)
+	}
+	return nil
+}
+
+// Time returns the time of the balance at index i.
+func (f *AccountForecast) Time(i int) time.Time { return f.Start.AddDate(0, 0, i) }
+
+// Balance returns the projected balance at the end of the day containing t.
+func (f *AccountForecast) Balance(t time.Time) int64 {
+	i := int(t.Sub(f.Start).Hours() / 24)
+	return f.Balances[min(max(i, 0), len(f.Balances)-1)]
+}
+
+// BelowFloor returns the first time the balance is projected to fall below the floor, and the balance at that time.
+func (f *AccountForecast) BelowFloor() (time.Time, int64, bool) {
+	if !f.Warn {
+		return time.Time{}, 0, false
+	}
+	for i, b := range f.Balances {
+		if b < f.Floor {
+			return f.Time(i), b, true
+		}
+	}
+	return time.Time{}, 0, false
+}
+
+// A flow is an amount entering or leaving an account on a given day.
+type flow struct {
+	account string
+	day     int // Days since the start of the forecast
+	amount  int64
+}
+
+// Forecast projects the daily balance of each open account declared in the configuration, from the day containing now
+// until the end of the month that is months after the current month. Balances start from the latest balance of each
+// account and change by:
+//
+// Budgets of groups, spread evenly over each budget period. In the current budget period, only the part of the budget
+// that has not yet been spent is projected. Budgets of groups that are not tied to an account are projected for the
+// forecast account.
+//
+// Recurring items declared in the configuration, and recurring payments detected among records, as returned by
+// Recurring. A detected payment is ignored if it belongs to a group with a budget, if a declared item has the same
+// name, or if it has not been charged for a full period.
+func (j *Journal) Forecast(now time.Time, months int) ([]AccountForecast, error) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	end := monthStart(start).AddDate(0, months+1, -1)
+	days := int(end.Sub(start).Hours()/24) + 1
+	var forecasts []AccountForecast
+	index := make(map[string]int)
+	for _, a := range j.accounts {
+		if a.Closed {
+			continue
+		}
+		f := AccountForecast{Account: a.record(), Start: start, Balances: make([]int64, days), Warn: a.Floor != nil}
+		if a.Floor != nil {
+			f.Floor = *a.Floor
+		}
+		// Liabilities normally have a negative balance, so they are only checked against an explicit floor
+		f.Warn = f.Warn || !f.Account.Liability()
+		var err error
+		if f.Balances[0], err = j.balance(a.Number, now); err != nil {
+			return nil, err
+		}
+		index[a.Number] = len(forecasts)
+		forecasts = append(forecasts, f)
+	}
+	if len(forecasts) == 0 {
+		return nil, nil
+	}
+	account := j.forecast.Account
+	if account == "" {
+		account = forecasts[0].Account.Number
+	}
+	flows, err := j.budgetFlows(start, end, account)
+	if err != nil {
+		return nil, err
+	}
+	recurring, err := j.recurringFlows(start, end)
+	if err != nil {
+		return nil, err
+	}
+	daily := make([][]int64, len(forecasts))
+	for i := range daily {
+		daily[i] = make([]int64, days)
+	}
+	for _, f := range append(flows, recurring...) {
+		if i, ok := index[f.account]; ok && f.day >= 0 && f.day < days {
+			daily[i][f.day] += f.amount
+		}
+	}
+	for i := range forecasts {
+		bs := forecasts[i].Balances
+		for d := 1; d < days; d++ {
+			bs[d] = bs[d-1] + daily[i][d]
+		}
+	}
+	return forecasts, nil
+}
+
+// budgetFlows returns the daily flows of group budgets from the day after start until end.
+func (j *Journal) budgetFlows(start, end time.Time, account string) ([]flow, error) {
+	var flows []flow
+	for _, name := range j.GroupNames() {
+		g := j.configGroup(name)
+		if g.Discard || !g.hasBudget() {
+			continue
+		}
+		rg := recordGroup(*g)
+		if j.budgetOwner(name) != name {
+			continue // The budget of an ancestor includes this group
+		}
+		acct := account
+		if g.Account != "" {
+			acct = g.Account
+		}
+		period := record.PeriodRange(rg.BudgetPeriod(), start)
+		rs, err := j.ReadAmortized(nil, period.Since, start.AddDate(0, 0, 1).Add(-time.Second))
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range j.filter(rs) {
+			if j.recordInGroup(r, name) {
+				rg.Records = append(rg.Records, r)
+			}
+		}
+		// The unspent part of the current period is spread over its remaining days
+		remaining := rg.Budget(period) - rg.Sum()
+		if (remaining > 0) != (rg.Budget(period) > 0) {
+			remaining = 0
+		}
+		left := int64(period.Until.Sub(start).Hours() / 24)
+		var prev int64
+		for d := 1; d <= int(end.Sub(start).Hours()/24); d++ {
+			t := start.AddDate(0, 0, d)
+			var cum int64
+			if !t.After(period.Until) {
+				cum = remaining * int64(d) / left
+			} else {
+				cum = remaining + rg.Budget(record.Range{Since: period.Until.AddDate(0, 0, 1), Until: t, Prorate: true})
+			}
+			flows = append(flows, flow{account: acct, day: d, amount: cum - prev})
+			prev = cum
+		}
+	}
+	return flows, nil
+}
+
+// budgetOwner returns the outermost group containing group name, including the group itself, that has a budget of its
+// own. It returns an empty string if there is no such group.
+func (j *Journal) budgetOwner(name string) string {
+	parts := strings.Split(name, record.Separator)
+	for i := range parts {
+		prefix := strings.Join(parts[:i+1], record.Separator)
+		if g := j.configGroup(prefix); g != nil && !g.Discard && g.hasBudget() {
+			return prefix
+		}
+	}
+	return ""
+}
+
+// recurringFlows returns the flows of recurring items and detected recurring payments from the day after start until
+// end.
+func (j *Journal) recurringFlows(start, end time.Time) ([]flow, error) {
+	var flows []flow
+	add := func(account string, c cadence, t time.Time, amount int64) {
+		for n := 0; ; n++ {
+			o := c.occurrence(t, n)
+			if o.After(end) {
+				break
+			}
+			if o.After(start) {
+				flows = append(flows, flow{account: account, day: int(o.Sub(start).Hours() / 24), amount: amount})
+			}
+		}
+	}
+	declared := make(map[string]bool)
+	for _, item := range j.recurring {
+		t := item.date
+		for n := -1; t.After(start); n-- {
+			t = item.cadence.occurrence(item.date, n)
+		}
+		add(item.Account, item.cadence, t, item.Amount)
+		declared[strings.ToLower(item.Name)] = true
+	}
+	rs, err := j.Read(nil, time.Time{}, start.AddDate(0, 0, 1).Add(-time.Second))
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range j.Recurring(rs, 3) {
+		last := r.Records[len(r.Records)-1]
+		if declared[strings.ToLower(r.Name)] || r.cadence.occurrence(r.Next(), 1).Before(start) {
+			continue
+		}
+		if g := j.findGroup(last); g != nil && j.budgetOwner(g.Name) != "" {
+			continue
+		}
+		add(last.Account.Number, r.cadence, r.Next(), last.Amount)
+	}
+	return flows, nil
+}
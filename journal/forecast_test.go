@@ -0,0 +1,106 @@
+package journal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestForecast(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[accounts]]
+number = "4.5.6"
+name = "Savings"
+floor = 100000
+
+[[recurring]]
+name = "Salary"
+account = "1.2.3"
+amount = 30000
+cadence = "monthly"
+date = "2018-06-25"
+
+[[recurring]]
+name = "Car loan"
+account = "4.5.6"
+amount = -15000
+cadence = "monthly"
+date = "2018-09-20"
+
+[[groups]]
+name = "Groceries"
+budget = -6200
+patterns = ["^Groceries"]
+
+[[groups]]
+name = "Ignored"
+discard = true
+patterns = ["^Gym"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("1.2.3", []record.Record{
+		{Time: date(2018, 4, 15), Text: "Netflix", Amount: -100},
+		{Time: date(2018, 5, 15), Text: "Netflix", Amount: -100},
+		{Time: date(2018, 6, 15), Text: "Netflix", Amount: -100},
+		{Time: date(2018, 4, 20), Text: "Gym", Amount: -300},
+		{Time: date(2018, 5, 20), Text: "Gym", Amount: -300},
+		{Time: date(2018, 6, 20), Text: "Gym", Amount: -300},
+		{Time: date(2018, 7, 10), Text: "Groceries", Amount: -2000, Balance: 50000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("4.5.6", []record.Record{
+		{Time: date(2018, 7, 1), Text: "Deposit", Amount: 120000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fs, err := j.Forecast(date(2018, 7, 10), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fs) != 2 {
+		t.Fatalf("want 2 forecasts, got %d", len(fs))
+	}
+	var tests = []struct {
+		account int
+		t       time.Time
+		balance int64
+	}{
+		{0, date(2018, 7, 10), 50000},
+		{0, date(2018, 7, 14), 49200}, // Unspent budget of 4200 is spread over the remaining 21 days of July
+		{0, date(2018, 7, 15), 48900}, // Netflix
+		{0, date(2018, 7, 20), 47600}, // Gym is discarded, but still paid
+		{0, date(2018, 7, 31), 75400}, // Salary
+		{0, date(2018, 8, 31), 98800},
+		{1, date(2018, 7, 10), 120000},
+		{1, date(2018, 8, 31), 90000},
+	}
+	for i, tt := range tests {
+		if got := fs[tt.account].Balance(tt.t); got != tt.balance {
+			t.Errorf("#%d: want balance %d at %s, got %d", i, tt.balance, tt.t, got)
+		}
+	}
+	if want, got := date(2018, 8, 31), fs[0].Time(len(fs[0].Balances)-1); !want.Equal(got) {
+		t.Errorf("want forecast until %s, got %s", want, got)
+	}
+	if _, _, below := fs[0].BelowFloor(); below {
+		t.Errorf("want %s above floor", fs[0].Account.Name)
+	}
+	if when, balance, below := fs[1].BelowFloor(); !below || !when.Equal(date(2018, 8, 20)) || balance != 90000 {
+		t.Errorf("want %s below floor at %s, got %t at %s (%d)", fs[1].Account.Name, date(2018, 8, 20), below, when, balance)
+	}
+}
@@ -0,0 +1,213 @@
+package journal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+// A Goal is a savings goal. Progress towards the goal is either the balance of a savings account, or the sum of
+// contributions assorted into a group.
+type Goal struct {
+	Name    string
+	Target  int64
+	Date    string
+	Since   string
+	Account string
+	Group   string
+	date    time.Time
+	since   time.Time
+	source  position
+}
+
+// GoalProgress is the progress towards a goal at a given time.
+type GoalProgress struct {
+	Name   string
+	Target int64
+	Saved  int64
+	Start  time.Time // Time when saving started
+	Date   time.Time // Time when the target should be reached
+	Now    time.Time
+}
+
+func (g *Goal) load() []error {
+	var errs []error
+	if g.Name == "" {
+		errs = append(errs, fmt.Errorf("invalid goal name: %q", g.Name))
+	}
+	if g.Target <= 0 {
+		errs = append(errs, fmt.Errorf("goal: %q: target must be positive, got %d", g.Name, g.Target))
+	}
+	var err error
+	if g.date, err = time.Parse("2006-01-02", g.Date); err != nil {
+		errs = append(errs, fmt.Errorf("goal: %q: invalid date: %q", g.Name, g.Date))
+	}
+	if g.Since != "" {
+		if g.since, err = time.Parse("2006-01-02", g.Since); err != nil {
+			errs = append(errs, fmt.Errorf("goal: %q: invalid since date: %q", g.Name, g.Since))
+		} else if !g.date.IsZero() && !g.since.Before(g.date) {
+			errs = append(errs, fmt.Errorf("goal: %q: since must be before date %s", g.Name, g.Date))
+		}
+	}
+	if (g.Account == "") == (g.Group == "") {
+		errs = append(errs, fmt.Errorf("goal: %q: exactly one of account and group must be set", g.Name))
+	}
+	return errs
+}
+
+// Remaining returns the amount left to save.
+func (p *GoalProgress) Remaining() int64 { return max(p.Target-p.Saved, 0) }
+
+// Months returns the number of whole months left until the target date. If less than a whole month is left, but the
+// target date has not passed, Months returns 1.
+func (p *GoalProgress) Months() int {
+	if p.Now.After(p.Date) {
+		return 0
+	}
+	months := (p.Date.Year()-p.Now.Year())*12 + int(p.Date.Month()-p.Now.Month())
+	if p.Date.Day() < p.Now.Day() {
+		months--
+	}
+	return max(months, 1)
+}
+
+// Monthly returns the contribution required each month to reach the target by the target date. If the target date
+// has passed, the whole remaining amount is returned.
+func (p *GoalProgress) Monthly() int64 {
+	months := p.Months()
+	if months == 0 {
+		return p.Remaining()
+	}
+	return (p.Remaining() + int64(months) - 1) / int64(months)
+}
+
+// Expected returns the amount that should have been saved by now, when saving at a constant rate from start until the
+// target date.
+func (p *GoalProgress) Expected() int64 {
+	total := p.Date.Sub(p.Start)
+	if total <= 0 || !p.Now.Before(p.Date) {
+		return p.Target
+	}
+	elapsed := max(p.Now.Sub(p.Start), 0)
+	return int64(float64(p.Target) * float64(elapsed) / float64(total))
+}
+
+// Status returns a description of the progress: "reached", "ahead", "behind" or "missed". A goal with nothing saved is
+// behind.
+func (p *GoalProgress) Status() string {
+	switch {
+	case p.Saved >= p.Target:
+		return "reached"
+	case p.Now.After(p.Date):
+		return "missed"
+	case p.Saved > 0 && p.Saved >= p.Expected():
+		return "ahead"
+	}
+	return "behind"
+}
+
+// Goals returns the progress of all goals at time now. The progress of a goal linked to an account is the balance of
+// the account, taken from the last record with a stored balance if any, and computed from the opening balance and
+// records otherwise. The progress of a goal linked to a group is the absolute sum of records matching the group, since
+// the start of the goal, even if the group is discarded. The start of a goal is its since date, or the time of the
+// first record counting towards it.
+func (j *Journal) Goals(now time.Time) ([]GoalProgress, error) {
+	var ps []GoalProgress
+	for _, g := range j.goals {
+		p := GoalProgress{Name: g.Name, Target: g.Target, Start: g.since, Date: g.date, Now: now}
+		var err error
+		if g.Account != "" {
+			err = j.accountProgress(&p, g.Account)
+		} else {
+			err = j.groupProgress(&p, g.Group)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p.Start.IsZero() {
+			p.Start = now
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+func (j *Journal) accountProgress(p *GoalProgress, number string) error {
+	if p.Start.IsZero() {
+		rs, err := j.db.SelectRecords(number)
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			t := time.Unix(r.Time, 0).UTC()
+			if !t.After(p.Now) && (p.Start.IsZero() || t.Before(p.Start)) {
+				p.Start = t
+			}
+		}
+	}
+	var err error
+	p.Saved, err = j.balance(number, p.Now)
+	return err
+}
+
+// balance returns the balance of the account identified by number at time now. The balance is taken from the last
+// record with a stored balance if any, and computed from the opening balance and records otherwise.
+func (j *Journal) balance(number string, now time.Time) (int64, error) {
+	rs, err := j.db.SelectRecords(number)
+	if err != nil {
+		return 0, err
+	}
+	a, _ := j.account(number)
+	var (
+		sum     int64
+		balance int64
+		last    time.Time
+		stored  bool
+	)
+	for _, r := range rs {
+		t := time.Unix(r.Time, 0).UTC()
+		if t.After(now) {
+			continue
+		}
+		if !t.Before(a.openingTime) {
+			sum += r.Amount
+		}
+		if r.Balance != 0 && !t.Before(last) {
+			balance, last, stored = r.Balance, t, true
+		}
+	}
+	if stored {
+		return balance, nil
+	}
+	return a.OpeningBalance + sum, nil
+}
+
+func (j *Journal) groupProgress(p *GoalProgress, group string) error {
+	rs, err := j.Read(nil, p.Start, p.Now)
+	if err != nil {
+		return err
+	}
+	var (
+		sum   int64
+		first time.Time
+	)
+	for _, r := range rs {
+		// Discarded groups, such as transfers to a savings account, count towards goals
+		if g := j.matchGroup(r); g == nil || !record.InGroup(g.Name, group) {
+			continue
+		}
+		sum += r.Amount
+		if first.IsZero() || r.Time.Before(first) {
+			first = r.Time
+		}
+	}
+	if p.Start.IsZero() {
+		p.Start = first
+	}
+	p.Saved = sum
+	if sum < 0 {
+		p.Saved = -sum
+	}
+	return nil
+}
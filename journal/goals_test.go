@@ -0,0 +1,142 @@
+package journal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestGoals(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[accounts]]
+number = "2.2.2"
+name = "Savings"
+type = "savings"
+
+[[groups]]
+name = "Car savings"
+patterns = ["^Transfer car"]
+
+[[groups]]
+name = "Boat savings"
+patterns = ["^Transfer boat"]
+
+[[groups]]
+name = "Savings transfers"
+discard = true
+patterns = ["^Transfer bike"]
+
+[[goals]]
+name = "Vacation"
+target = 10000
+date = "2018-12-31"
+since = "2018-01-01"
+account = "2.2.2"
+
+[[goals]]
+name = "Car"
+target = 50000
+date = "2018-06-30"
+group = "Car savings"
+
+[[goals]]
+name = "Boat"
+target = 8000
+date = "2018-12-31"
+group = "Boat savings"
+
+[[goals]]
+name = "Bike"
+target = 2000
+date = "2018-12-31"
+group = "Savings transfers"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("2.2.2", []record.Record{
+		{Time: date(2018, 1, 15), Text: "Deposit", Amount: 2000, Balance: 2000},
+		{Time: date(2018, 3, 15), Text: "Deposit", Amount: 2000, Balance: 4000},
+		{Time: date(2018, 5, 15), Text: "Deposit", Amount: 2000, Balance: 6000}, // After now
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("1.2.3", []record.Record{
+		{Time: date(2018, 2, 1), Text: "Transfer car", Amount: -1000},
+		{Time: date(2018, 3, 1), Text: "Transfer car", Amount: -1000},
+		{Time: date(2018, 3, 2), Text: "Groceries", Amount: -1000},
+		{Time: date(2018, 3, 3), Text: "Transfer bike", Amount: -1000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ps, err := j.Goals(date(2018, 4, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name    string
+		saved   int64
+		months  int
+		monthly int64
+		status  string
+	}{
+		{"Vacation", 4000, 8, 750, "ahead"},
+		{"Car", 2000, 2, 24000, "behind"},
+		{"Boat", 0, 8, 1000, "behind"},  // Not started
+		{"Bike", 1000, 8, 125, "ahead"}, // Discarded group
+	}
+	if len(ps) != len(tests) {
+		t.Fatalf("want %d goals, got %d", len(tests), len(ps))
+	}
+	for i, tt := range tests {
+		p := ps[i]
+		if p.Name != tt.name || p.Saved != tt.saved || p.Months() != tt.months || p.Monthly() != tt.monthly || p.Status() != tt.status {
+			t.Errorf("#%d: want %s: saved = %d, months = %d, monthly = %d, status = %s; got %s: saved = %d, months = %d, monthly = %d, status = %s",
+				i, tt.name, tt.saved, tt.months, tt.monthly, tt.status, p.Name, p.Saved, p.Months(), p.Monthly(), p.Status())
+		}
+	}
+	if want, got := date(2018, 2, 1), ps[1].Start; !want.Equal(got) {
+		t.Errorf("want start %s, got %s", want, got)
+	}
+
+	ps, err = j.Goals(date(2019, 1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "missed", ps[0].Status(); want != got {
+		t.Errorf("want status %q, got %q", want, got)
+	}
+	if want, got := int64(4000), ps[0].Monthly(); want != got {
+		t.Errorf("want monthly %d, got %d", want, got)
+	}
+
+	var invalid = []struct {
+		goal string
+		err  string
+	}{
+		{"target = 100\ndate = \"2018-12-31\"\naccount = \"1.2.3\"\ngroup = \"A\"", `goal: "A": exactly one of account and group must be set`},
+		{"target = 100\ndate = \"2018\"\naccount = \"1.2.3\"", `goal: "A": invalid date: "2018"`},
+		{"target = -100\ndate = \"2018-12-31\"\naccount = \"1.2.3\"", `goal: "A": target must be positive, got -100`},
+		{"target = 100\ndate = \"2018-12-31\"\nsince = \"2019-01-01\"\naccount = \"1.2.3\"", `goal: "A": since must be before date 2018-12-31`},
+	}
+	for i, tt := range invalid {
+		conf, err := readConfig(strings.NewReader("Database = \":memory:\"\n[[goals]]\nname = \"A\"\n" + tt.goal))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conf.load(); err == nil || err.Error() != tt.err {
+			t.Errorf("#%d: want error %q, got %v", i, tt.err, err)
+		}
+	}
+}
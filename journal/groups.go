@@ -0,0 +1,182 @@
+package journal
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/mpolden/journal/sql"
+)
+
+// A GroupSummary describes a group and where it is defined.
+type GroupSummary struct {
+	Name     string
+	Config   bool
+	Database bool
+	Rules    int
+	Pins     int
+}
+
+// Source returns where this group is defined: "config", "database" or "both".
+func (s *GroupSummary) Source() string {
+	switch {
+	case s.Config && s.Database:
+		return "both"
+	case s.Database:
+		return "database"
+	}
+	return "config"
+}
+
+// loadStoredGroups merges groups stored in the database with the groups from config. Stored groups are evaluated after
+// all groups from config, in the order they were first added to the database. A stored group sharing its name with a
+// group from config inherits all settings of the latter, except its patterns, rules and pins.
+func (j *Journal) loadStoredGroups() error {
+	rules, err := j.db.SelectGroupRules()
+	if err != nil {
+		return err
+	}
+	groups := append([]Group(nil), j.configGroups...)
+	stored := make(map[string]int)
+	for _, r := range rules {
+		i, ok := stored[r.Group]
+		if !ok {
+			g := Group{Name: r.Group}
+			for _, cg := range j.configGroups {
+				if cg.Name == r.Group {
+					g = cg
+					g.Patterns, g.Rules, g.IDs, g.rules = nil, nil, nil, nil
+					break
+				}
+			}
+			g.stored = true
+			groups = append(groups, g)
+			i = len(groups) - 1
+			stored[r.Group] = i
+		}
+		switch r.Kind {
+		case sql.PatternRule:
+			groups[i].Patterns = append(groups[i].Patterns, r.Value)
+		case sql.PinRule:
+			groups[i].IDs = append(groups[i].IDs, r.Value)
+		}
+	}
+	for i := range groups {
+		if !groups[i].stored {
+			continue
+		}
+		if errs := groups[i].load(); len(errs) > 0 {
+			return fmt.Errorf("database: %w", errs[0])
+		}
+	}
+	j.groups = groups
+	return nil
+}
+
+func (j *Journal) hasGroup(name string) bool { return j.configGroup(name) != nil }
+
+func (j *Journal) addGroupRule(rule sql.GroupRule) (int64, error) {
+	n, err := j.db.AddGroupRule(rule)
+	if err != nil {
+		return 0, err
+	}
+	return n, j.loadStoredGroups()
+}
+
+// Groups returns a summary of all groups, in order of precedence.
+func (j *Journal) Groups() []GroupSummary {
+	var summaries []GroupSummary
+	index := make(map[string]int)
+	for _, g := range j.groups {
+		i, ok := index[g.Name]
+		if !ok {
+			summaries = append(summaries, GroupSummary{Name: g.Name})
+			i = len(summaries) - 1
+			index[g.Name] = i
+		}
+		s := &summaries[i]
+		if g.stored {
+			s.Database = true
+		} else {
+			s.Config = true
+		}
+		s.Rules += len(g.rules)
+		s.Pins += len(g.IDs)
+	}
+	return summaries
+}
+
+// AddGroup stores a new group in the database. The group is evaluated after all existing groups.
+func (j *Journal) AddGroup(name string) error {
+	if !validGroupName(name) {
+		return fmt.Errorf("invalid group name: %q", name)
+	}
+	if j.hasGroup(name) {
+		return fmt.Errorf("group %q already exists", name)
+	}
+	_, err := j.addGroupRule(sql.GroupRule{Group: name, Kind: sql.GroupDeclaration})
+	return err
+}
+
+// RemoveGroup removes a group and all of its rules from the database. Groups defined in config cannot be removed, but
+// any rules added to them in the database are.
+func (j *Journal) RemoveGroup(name string) (int64, error) {
+	n, err := j.db.DeleteGroup(name)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		if j.hasGroup(name) {
+			return 0, fmt.Errorf("group %q is defined in config and can only be removed there", name)
+		}
+		return 0, fmt.Errorf("group %q does not exist", name)
+	}
+	return n, j.loadStoredGroups()
+}
+
+// AddGroupPattern stores pattern as a rule of group in the database.
+func (j *Journal) AddGroupPattern(group, pattern string) error {
+	if !j.hasGroup(group) {
+		return fmt.Errorf("group %q does not exist", group)
+	}
+	if len(pattern) == 0 {
+		return fmt.Errorf("group: %q: invalid pattern: %q", group, pattern)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return err
+	}
+	_, err := j.addGroupRule(sql.GroupRule{Group: group, Kind: sql.PatternRule, Value: pattern})
+	return err
+}
+
+// PinRecord pins the record identified by id to group, by storing the pin in the database.
+func (j *Journal) PinRecord(group, id string) error {
+	if !j.hasGroup(group) {
+		return fmt.Errorf("group %q does not exist", group)
+	}
+	if _, ok, err := j.FindRecord(id); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("record %s not found", id)
+	}
+	_, err := j.addGroupRule(sql.GroupRule{Group: group, Kind: sql.PinRule, Value: id})
+	return err
+}
+
+// UnpinRecord removes all pins of the record identified by id from the database, and returns the number of removed
+// pins. Pins defined in config cannot be removed.
+func (j *Journal) UnpinRecord(id string) (int64, error) {
+	n, err := j.db.DeleteGroupRules(sql.PinRule, id)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		for _, g := range j.configGroups {
+			if slices.Contains(g.IDs, id) {
+				return 0, fmt.Errorf("record %s is pinned to group %q in config and can only be unpinned there", id, g.Name)
+			}
+		}
+		return 0, fmt.Errorf("record %s is not pinned", id)
+	}
+	return n, j.loadStoredGroups()
+}
@@ -0,0 +1,156 @@
+package journal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestStoredGroups(t *testing.T) {
+	j := testJournal(t)
+	rs := []record.Record{
+		{Time: date(2018, 1, 1), Text: "Foo 1", Amount: 42},
+		{Time: date(2018, 1, 2), Text: "Qux shop", Amount: 42},
+		{Time: date(2018, 1, 3), Text: "Quux", Amount: 42},
+	}
+	if _, err := j.Write("1234.56.78900", rs); err != nil {
+		t.Fatal(err)
+	}
+	rs, err := j.Read(nil, date(2018, 1, 1), date(2018, 1, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	groupOf := func(text string) string {
+		for _, r := range rs {
+			if r.Text == text {
+				return j.findGroup(r).Name
+			}
+		}
+		t.Fatalf("record %q not found", text)
+		return ""
+	}
+	idOf := func(text string) string {
+		for _, r := range rs {
+			if r.Text == text {
+				return r.ID()
+			}
+		}
+		t.Fatalf("record %q not found", text)
+		return ""
+	}
+
+	if err := j.AddGroup("Shopping"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Travel", "Shopping", "a//b"} {
+		if err := j.AddGroup(name); err == nil {
+			t.Errorf("want error when adding group %q", name)
+		}
+	}
+	if err := j.AddGroupPattern("Shopping", "^Qux"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.AddGroupPattern("Travel", "^Quux"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.AddGroupPattern("Shopping", "^Foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.AddGroupPattern("Nope", "^Foo"); err == nil {
+		t.Error("want error when adding pattern to unknown group")
+	}
+	if err := j.AddGroupPattern("Shopping", "("); err == nil {
+		t.Error("want error when adding invalid pattern")
+	}
+	// Config rules precede stored rules
+	if want, got := "Shopping", groupOf("Qux shop"); want != got {
+		t.Errorf("want group %q, got %q", want, got)
+	}
+	if want, got := "Travel", groupOf("Quux"); want != got {
+		t.Errorf("want group %q, got %q", want, got)
+	}
+	if want, got := "Travel", groupOf("Foo 1"); want != got {
+		t.Errorf("want group %q, got %q", want, got)
+	}
+
+	// Pins precede all rules
+	if err := j.PinRecord("Shopping", idOf("Foo 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.PinRecord("Shopping", "0123456789"); err == nil {
+		t.Error("want error when pinning unknown record")
+	}
+	if want, got := "Shopping", groupOf("Foo 1"); want != got {
+		t.Errorf("want group %q, got %q", want, got)
+	}
+	want := []GroupSummary{
+		{Name: "Travel", Config: true, Database: true, Rules: 2},
+		{Name: "Groceries", Config: true, Rules: 2},
+		{Name: "Misc", Config: true, Pins: 1},
+		{Name: "Other", Config: true, Rules: 1},
+		{Name: "Unimportant", Config: true, Rules: 1},
+		{Name: "Shopping", Database: true, Rules: 2, Pins: 1},
+	}
+	if got := j.Groups(); !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	if n, err := j.UnpinRecord(idOf("Foo 1")); err != nil || n != 1 {
+		t.Errorf("want 1 removed pin, got %d (err = %v)", n, err)
+	}
+	if want, got := "Travel", groupOf("Foo 1"); want != got {
+		t.Errorf("want group %q, got %q", want, got)
+	}
+	for _, id := range []string{idOf("Foo 1"), "45defdf469"} {
+		if _, err := j.UnpinRecord(id); err == nil {
+			t.Errorf("want error when unpinning %s", id)
+		}
+	}
+
+	if n, err := j.RemoveGroup("Shopping"); err != nil || n != 3 {
+		t.Errorf("want 3 removed rules, got %d (err = %v)", n, err)
+	}
+	if n, err := j.RemoveGroup("Travel"); err != nil || n != 1 {
+		t.Errorf("want 1 removed rule, got %d (err = %v)", n, err)
+	}
+	for _, name := range []string{"Travel", "Shopping"} {
+		if _, err := j.RemoveGroup(name); err == nil {
+			t.Errorf("want error when removing group %q", name)
+		}
+	}
+	if want, got := j.DefaultGroup, groupOf("Qux shop"); want != got {
+		t.Errorf("want group %q, got %q", want, got)
+	}
+}
+
+func TestStoredGroupSettings(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[groups]]
+name = "Groceries"
+budget = -5000
+rollover = true
+warnAt = 0.5
+patterns = ["^Rema"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.AddGroupPattern("Groceries", "^Kiwi"); err != nil {
+		t.Fatal(err)
+	}
+	g := j.groups[len(j.groups)-1]
+	if !g.stored || !g.Rollover || g.WarnAt != 0.5 || g.Budget != -5000 {
+		t.Errorf("want stored group with settings from config, got %+v", g)
+	}
+	if want := []string{"^Kiwi"}; !reflect.DeepEqual(want, g.Patterns) {
+		t.Errorf("want patterns %q, got %q", want, g.Patterns)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os/user"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -22,93 +23,656 @@ import (
 	"github.com/mpolden/journal/sql"
 )
 
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
 // Account represents a financial account.
 type Account struct {
-	Number string
-	Name   string
+	Number         string
+	Name           string
+	Type           string
+	Institution    string
+	OpeningBalance int64
+	OpeningDate    string
+	Closed         bool
+	Floor          *int64 // Minimum balance in forecasts
+	openingTime    time.Time
+	source         position
+}
+
+// AccountGroup represents a named set of accounts.
+type AccountGroup struct {
+	Name     string
+	Accounts []string
+	source   position
 }
 
 // Group represents a group configuration which decides how records should be assorted into groups.
 type Group struct {
-	Name     string
-	Account  string
-	Budget   int64
-	Budgets  [12]int64
-	Patterns []string
-	patterns []*regexp.Regexp
-	IDs      []string
-	Discard  bool
+	Name          string
+	Parent        string
+	Account       string
+	Budget        int64
+	Budgets       []int64
+	Rollover      bool
+	BudgetPeriod  string
+	BudgetHistory []BudgetPeriod
+	Patterns      []string
+	Rules         []Rule
+	IDs           []string
+	Discard       bool
+	Income        bool
+	WarnAt        float64
+	rules         []Rule
+	stored        bool
+	source        position
+}
+
+// A BudgetPeriod is a budget of a group taking effect from the month containing Since. It replaces the group's budget
+// and any earlier budget period.
+type BudgetPeriod struct {
+	Since   string
+	Budget  int64
+	Budgets []int64
+	since   time.Time
+}
+
+// Match represents the evaluation of a pin or rule against a record.
+type Match struct {
+	Group      string
+	Rule       string
+	Pinned     bool
+	Classified bool
+	Matched    bool
+	Discard    bool
+}
+
+// Rule represents a set of conditions that must all be true for a record to match. Unset conditions are ignored.
+type Rule struct {
+	Pattern     string
+	MinAmount   *int64
+	MaxAmount   *int64
+	Sign        string
+	Since       string
+	Until       string
+	Weekdays    []string
+	DaysOfMonth []int
+	Amortize    int // Number of months to spread matching records over
+	pattern     *regexp.Regexp
+	since       time.Time
+	until       time.Time
+	weekdays    []time.Weekday
 }
 
 // Config represents a journal's configuration.
 type Config struct {
-	Database     string
-	Comma        string
-	DefaultGroup string
-	Accounts     []Account
-	Groups       []Group
+	Include           []string
+	Database          string
+	Comma             string
+	DefaultGroup      string
+	Classify          bool
+	ClassifyThreshold *float64
+	Envelope          bool
+	TransferDays      int
+	Alert             Alert
+	Forecast          Forecast
+	Recurring         []RecurringItem
+	Amortize          []Amortization
+	Goals             []Goal
+	Accounts          []Account
+	AccountGroups     []AccountGroup
+	Groups            []Group
 }
 
 // Journal implements a journal of financial records.
 type Journal struct {
-	accounts     []Account
-	groups       []Group
-	db           *sql.Client
-	Comma        string
-	DefaultGroup string
-	Discarding   bool
+	accounts          []Account
+	accountGroups     []AccountGroup
+	configGroups      []Group
+	groups            []Group
+	classifying       bool
+	classifier        *classifier
+	classifyThreshold float64
+	envelope          bool
+	alert             Alert
+	transferDays      int
+	forecast          Forecast
+	recurring         []RecurringItem
+	goals             []Goal
+	amortize          map[string]int // Record ID to number of months
+	maxAmortize       int
+	db                *sql.Client
+	Comma             string
+	DefaultGroup      string
+	Discarding        bool
+	Filter            Filter // Records counted when listing, and in budgets, alerts and anomalies
 }
 
 // Writes represents statistics of a journal's updates.
 type Writes struct {
-	Account int64
-	Record  int64
+	Account  int64
+	Record   int64
+	Transfer int64
+}
+
+// A Filter selects records. The zero value selects all records in open accounts that are not part of a transfer.
+type Filter struct {
+	Type      string // Type of account to select records from. Records in accounts of any type are selected if empty
+	Closed    bool   // Whether to select records in closed accounts
+	Transfers bool   // Whether to select records that are part of a transfer
+}
+
+// Keep returns whether record r is selected by this filter. A slice of an amortized record is selected if the original
+// record is.
+func (f Filter) Keep(r record.Record) bool {
+	if f.Type != "" && r.Account.Type != f.Type {
+		return false
+	}
+	if r.Account.Closed && !f.Closed {
+		return false
+	}
+	o := r.Original()
+	return f.Transfers || !o.Transfer
+}
+
+func (j *Journal) filter(rs []record.Record) []record.Record {
+	var filtered []record.Record
+	for _, r := range rs {
+		if j.Filter.Keep(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
 }
 
 func (c *Config) load() error {
-	if len(c.Database) == 0 {
-		return fmt.Errorf("invalid database path: %q", c.Database)
+	for _, p := range c.validate() {
+		if p.fatal {
+			return p
+		}
+	}
+	return nil
+}
+
+// validate checks this configuration and prepares it for use. It returns all problems found. Fatal problems prevent
+// the configuration from being used.
+func (c *Config) validate() []ConfigProblem {
+	var problems []ConfigProblem
+	report := func(source position, fatal bool, err error) {
+		problems = append(problems, ConfigProblem{Source: source.String(), Message: err.Error(), fatal: fatal})
 	}
-	if c.Database[0] == '~' {
+	if len(c.Database) == 0 {
+		report(position{}, true, fmt.Errorf("invalid database path: %q", c.Database))
+	} else if c.Database[0] == '~' {
 		if len(c.Database) > 1 && c.Database[1] != '/' {
-			return fmt.Errorf("invalid database path: %q", c.Database)
+			report(position{}, true, fmt.Errorf("invalid database path: %q", c.Database))
+		} else if user, err := user.Current(); err != nil {
+			report(position{}, true, err)
+		} else {
+			c.Database = filepath.Join(user.HomeDir, c.Database[1:])
+		}
+	}
+	if t := c.ClassifyThreshold; t != nil && (*t < 0 || *t > 1) {
+		report(position{}, true, fmt.Errorf("invalid classify threshold: %f", *t))
+	}
+	if c.TransferDays < 0 {
+		report(position{}, true, fmt.Errorf("invalid transfer days: %d", c.TransferDays))
+	}
+	if err := c.Alert.load(); err != nil {
+		report(position{}, true, err)
+	}
+	declared := make(map[string]position)
+	for i, a := range c.Accounts {
+		if err := c.Accounts[i].load(); err != nil {
+			report(a.source, true, err)
+		}
+		if first, ok := declared[a.Number]; ok {
+			report(a.source, false, fmt.Errorf("account: %q: already declared at %s", a.Number, first))
+		} else {
+			declared[a.Number] = a.source
+		}
+	}
+	for _, ag := range c.AccountGroups {
+		for _, err := range c.loadAccountGroup(ag) {
+			report(ag.source, true, err)
+		}
+	}
+	if c.Forecast.Account != "" && !c.hasAccount(c.Forecast.Account) {
+		report(position{}, false, fmt.Errorf("forecast: account is not declared: %q", c.Forecast.Account))
+	}
+	for i := range c.Recurring {
+		r := &c.Recurring[i]
+		if err := r.load(); err != nil {
+			report(position{}, true, err)
+		} else if !c.hasAccount(r.Account) {
+			report(position{}, false, fmt.Errorf("recurring: %q: account is not declared: %q", r.Name, r.Account))
 		}
-		user, err := user.Current()
+	}
+	for i, a := range c.Amortize {
+		if err := a.load(); err != nil {
+			report(position{}, true, fmt.Errorf("amortize[%d]: %w", i, err))
+		}
+	}
+	// Groups are copied, as their names are qualified by their parent
+	c.Groups = append([]Group(nil), c.Groups...)
+	for i := range c.Groups {
+		g := &c.Groups[i]
+		g.Name = groupName(g.Name, g.Parent)
+		for _, err := range g.load() {
+			report(g.source, true, err)
+		}
+		if g.Account != "" && !c.hasAccount(g.Account) {
+			report(g.source, false, fmt.Errorf("group: %q: account is not declared: %q", g.Name, g.Account))
+		}
+		if g.Rollover && !g.hasBudget() {
+			report(g.source, false, fmt.Errorf("group: %q: rollover has no effect without a budget", g.Name))
+		}
+	}
+	for i := range c.Goals {
+		g := &c.Goals[i]
+		for _, err := range g.load() {
+			report(g.source, true, err)
+		}
+		if g.Account != "" && !c.hasAccount(g.Account) {
+			report(g.source, false, fmt.Errorf("goal: %q: account is not declared: %q", g.Name, g.Account))
+		}
+	}
+	return problems
+}
+
+// A position is the location of a declaration in a configuration file.
+type position struct {
+	file string
+	line int
+}
+
+func (p position) String() string {
+	if p.line == 0 {
+		return p.file
+	}
+	return fmt.Sprintf("%s:%d", p.file, p.line)
+}
+
+func (a *Account) load() error {
+	if len(a.Number) == 0 {
+		return fmt.Errorf("invalid account number: %q", a.Number)
+	}
+	switch a.Type {
+	case "", record.CheckingAccount, record.SavingsAccount, record.CreditCardAccount, record.LoanAccount:
+	default:
+		return fmt.Errorf("account: %q: invalid type: %q", a.Number, a.Type)
+	}
+	if a.OpeningDate != "" {
+		t, err := time.Parse("2006-01-02", a.OpeningDate)
 		if err != nil {
-			return err
+			return fmt.Errorf("account: %q: invalid opening date: %q", a.Number, a.OpeningDate)
 		}
-		c.Database = filepath.Join(user.HomeDir, c.Database[1:])
+		a.openingTime = t
 	}
-	for _, a := range c.Accounts {
-		if len(a.Number) == 0 {
-			return fmt.Errorf("invalid account number: %q", a.Number)
+	return nil
+}
+
+func (c *Config) loadAccountGroup(ag AccountGroup) []error {
+	if len(ag.Name) == 0 {
+		return []error{fmt.Errorf("invalid account group name: %q", ag.Name)}
+	}
+	if len(ag.Accounts) == 0 {
+		return []error{fmt.Errorf("account group: %q: no accounts", ag.Name)}
+	}
+	var errs []error
+	for _, number := range ag.Accounts {
+		if !c.hasAccount(number) {
+			errs = append(errs, fmt.Errorf("account group: %q: invalid account: %q", ag.Name, number))
 		}
 	}
-	for i, g := range c.Groups {
-		if len(g.Name) == 0 {
-			return fmt.Errorf("invalid group name: %q", g.Name)
+	return errs
+}
+
+func validGroupName(name string) bool {
+	for _, part := range strings.Split(name, record.Separator) {
+		if len(part) == 0 {
+			return false
 		}
-		for _, pattern := range g.Patterns {
-			if len(pattern) == 0 {
-				return fmt.Errorf("group: %q: invalid pattern: %q", g.Name, pattern)
-			}
-			p, err := regexp.Compile(pattern)
-			if err != nil {
-				return err
-			}
-			c.Groups[i].patterns = append(c.Groups[i].patterns, p)
+	}
+	return true
+}
+
+// load compiles the patterns and rules of this group. It returns all problems found.
+func (g *Group) load() []error {
+	var errs []error
+	if !validGroupName(g.Name) {
+		errs = append(errs, fmt.Errorf("invalid group name: %q", g.Name))
+	}
+	if len(g.Budgets) > 0 && len(g.Budgets) != 12 {
+		errs = append(errs, fmt.Errorf("group: %q: budgets must have 12 values, got %d", g.Name, len(g.Budgets)))
+	}
+	if g.WarnAt < 0 {
+		errs = append(errs, fmt.Errorf("group: %q: invalid warnAt: %f", g.Name, g.WarnAt))
+	}
+	monthly := true
+	switch g.BudgetPeriod {
+	case "", record.MonthPeriod:
+	case record.QuarterPeriod, record.YearPeriod, record.WeekPeriod:
+		monthly = false
+		if len(g.Budgets) > 0 {
+			errs = append(errs, fmt.Errorf("group: %q: budgets cannot be combined with budget period %q", g.Name, g.BudgetPeriod))
 		}
+	default:
+		errs = append(errs, fmt.Errorf("group: %q: invalid budget period: %q", g.Name, g.BudgetPeriod))
+	}
+	for i := range g.BudgetHistory {
+		p := &g.BudgetHistory[i]
+		var err error
+		if p.since, err = time.Parse("2006-01-02", p.Since); err != nil {
+			errs = append(errs, fmt.Errorf("group: %q: budgetHistory[%d]: invalid since date: %q", g.Name, i, p.Since))
+		} else if i > 0 && !p.since.After(g.BudgetHistory[i-1].since) {
+			errs = append(errs, fmt.Errorf("group: %q: budgetHistory[%d]: since must be later than %s", g.Name, i, g.BudgetHistory[i-1].Since))
+		}
+		if len(p.Budgets) > 0 && !monthly {
+			errs = append(errs, fmt.Errorf("group: %q: budgetHistory[%d]: budgets cannot be combined with budget period %q", g.Name, i, g.BudgetPeriod))
+		} else if len(p.Budgets) > 0 && len(p.Budgets) != 12 {
+			errs = append(errs, fmt.Errorf("group: %q: budgetHistory[%d]: budgets must have 12 values, got %d", g.Name, i, len(p.Budgets)))
+		}
+	}
+	g.rules = nil
+	for i, pattern := range g.Patterns {
+		if len(pattern) == 0 {
+			errs = append(errs, fmt.Errorf("group: %q: patterns[%d]: invalid pattern: %q", g.Name, i, pattern))
+			continue
+		}
+		rule := Rule{Pattern: pattern}
+		if err := rule.load(); err != nil {
+			errs = append(errs, fmt.Errorf("group: %q: patterns[%d]: %w", g.Name, i, err))
+			continue
+		}
+		g.rules = append(g.rules, rule)
+	}
+	for i, rule := range g.Rules {
+		if err := rule.load(); err != nil {
+			errs = append(errs, fmt.Errorf("group: %q: rules[%d]: %w", g.Name, i, err))
+			continue
+		}
+		g.rules = append(g.rules, rule)
+	}
+	return errs
+}
+
+func newMatch(g *Group, rule *Rule, r record.Record, matched bool) Match {
+	m := Match{Group: g.Name, Matched: matched, Discard: g.Discard}
+	if rule == nil {
+		r = r.Original()
+		m.Rule = r.ID()
+		m.Pinned = true
+	} else {
+		m.Rule = rule.String()
+	}
+	return m
+}
+
+// String returns a description of the conditions in this rule. The description of a rule containing only a pattern is
+// the pattern itself.
+func (r *Rule) String() string {
+	var conds []string
+	if r.Pattern != "" {
+		conds = append(conds, fmt.Sprintf("pattern = %q", r.Pattern))
+	}
+	if r.MinAmount != nil {
+		conds = append(conds, fmt.Sprintf("minAmount = %d", *r.MinAmount))
+	}
+	if r.MaxAmount != nil {
+		conds = append(conds, fmt.Sprintf("maxAmount = %d", *r.MaxAmount))
+	}
+	if r.Sign != "" {
+		conds = append(conds, fmt.Sprintf("sign = %q", r.Sign))
+	}
+	if r.Since != "" {
+		conds = append(conds, fmt.Sprintf("since = %q", r.Since))
+	}
+	if r.Until != "" {
+		conds = append(conds, fmt.Sprintf("until = %q", r.Until))
+	}
+	if len(r.Weekdays) > 0 {
+		conds = append(conds, fmt.Sprintf("weekdays = %q", r.Weekdays))
+	}
+	if len(r.DaysOfMonth) > 0 {
+		conds = append(conds, fmt.Sprintf("daysOfMonth = %d", r.DaysOfMonth))
+	}
+	if len(conds) == 1 && r.Pattern != "" {
+		return r.Pattern
+	}
+	return strings.Join(conds, ", ")
+}
 
+func (r *Rule) load() error {
+	if r.Pattern == "" && r.MinAmount == nil && r.MaxAmount == nil && r.Sign == "" && r.Since == "" && r.Until == "" &&
+		len(r.Weekdays) == 0 && len(r.DaysOfMonth) == 0 {
+		return fmt.Errorf("rule has no conditions")
+	}
+	if r.Pattern != "" {
+		p, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return err
+		}
+		r.pattern = p
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+		return fmt.Errorf("invalid amount range: [%d, %d]", *r.MinAmount, *r.MaxAmount)
+	}
+	switch r.Sign {
+	case "", "income", "expense":
+	default:
+		return fmt.Errorf("invalid sign: %q", r.Sign)
+	}
+	var err error
+	if r.Since != "" {
+		if r.since, err = time.Parse("2006-01-02", r.Since); err != nil {
+			return fmt.Errorf("invalid since date: %q", r.Since)
+		}
+	}
+	if r.Until != "" {
+		if r.until, err = time.Parse("2006-01-02", r.Until); err != nil {
+			return fmt.Errorf("invalid until date: %q", r.Until)
+		}
+	}
+	r.weekdays = nil
+	for _, name := range r.Weekdays {
+		weekday, ok := weekdays[strings.ToLower(name)]
+		if !ok {
+			return fmt.Errorf("invalid weekday: %q", name)
+		}
+		r.weekdays = append(r.weekdays, weekday)
+	}
+	for _, day := range r.DaysOfMonth {
+		if day < 1 || day > 31 {
+			return fmt.Errorf("invalid day of month: %d", day)
+		}
+	}
+	if r.Amortize < 0 {
+		return fmt.Errorf("invalid number of months to amortize over: %d", r.Amortize)
 	}
 	return nil
 }
 
+func (r *Rule) match(rec record.Record) bool {
+	if r.pattern != nil && !r.pattern.MatchString(rec.Text) {
+		return false
+	}
+	if r.MinAmount != nil && rec.Amount < *r.MinAmount {
+		return false
+	}
+	if r.MaxAmount != nil && rec.Amount > *r.MaxAmount {
+		return false
+	}
+	if (r.Sign == "income" && rec.Amount < 0) || (r.Sign == "expense" && rec.Amount >= 0) {
+		return false
+	}
+	if !r.since.IsZero() && rec.Time.Before(r.since) {
+		return false
+	}
+	if !r.until.IsZero() && rec.Time.After(r.until) {
+		return false
+	}
+	if len(r.weekdays) > 0 && !slices.Contains(r.weekdays, rec.Time.Weekday()) {
+		return false
+	}
+	if len(r.DaysOfMonth) > 0 && !slices.Contains(r.DaysOfMonth, rec.Time.Day()) {
+		return false
+	}
+	return true
+}
+
+func (c *Config) hasAccount(number string) bool {
+	for _, a := range c.Accounts {
+		if a.Number == number {
+			return true
+		}
+	}
+	return false
+}
+
 func readConfig(r io.Reader) (Config, error) {
 	var conf Config
 	_, err := toml.DecodeReader(r, &conf)
 	return conf, err
 }
 
+// readConfigFile reads the configuration file name, and merges any files it includes. Included files are merged in the
+// order they're listed, and files matching the same glob pattern are merged in lexical order. Accounts, account groups
+// and groups of an included file are placed after those of the including file.
+func readConfigFile(name string) (Config, error) {
+	var r configReader
+	conf, _, err := r.read(name, nil)
+	return conf, err
+}
+
+// configReader reads configuration files and the files they include.
+type configReader struct {
+	check    bool // Whether to collect problems, instead of failing on the first one
+	problems []ConfigProblem
+}
+
+// fail reports err at source. It returns err if this reader is not checking, and nil otherwise.
+func (r *configReader) fail(source position, err error) error {
+	p := ConfigProblem{Source: source.String(), Message: err.Error(), fatal: true}
+	if !r.check {
+		return p
+	}
+	r.problems = append(r.problems, p)
+	return nil
+}
+
+// read reads configuration file name. It returns false if the file could not be parsed.
+func (r *configReader) read(name string, parents []string) (Config, bool, error) {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return Config{}, false, err
+	}
+	if slices.Contains(parents, abs) {
+		err := r.fail(position{file: name}, fmt.Errorf("include cycle: %s", strings.Join(append(parents, abs), " -> ")))
+		return Config{}, false, err
+	}
+	data, err := os.ReadFile(name)
+	if err != nil {
+		if len(parents) == 0 {
+			return Config{}, false, err
+		}
+		return Config{}, false, r.fail(position{file: name}, err)
+	}
+	var conf Config
+	md, err := toml.Decode(string(data), &conf)
+	if err != nil {
+		return Config{}, false, r.fail(position{file: name}, err)
+	}
+	conf.locate(name, string(data))
+	if r.check {
+		r.checkKeys(name, string(data), md.Undecoded())
+	}
+	for _, pattern := range conf.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(name), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			if err := r.fail(position{file: name}, fmt.Errorf("invalid include: %q: %w", pattern, err)); err != nil {
+				return Config{}, false, err
+			}
+			continue
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			if err := r.fail(position{file: name}, fmt.Errorf("included file does not exist: %s", pattern)); err != nil {
+				return Config{}, false, err
+			}
+		}
+		for _, m := range matches {
+			inc, ok, err := r.read(m, append(parents, abs))
+			if err != nil {
+				return Config{}, false, err
+			}
+			if !ok {
+				continue
+			}
+			if inc.Database != "" || inc.Comma != "" || inc.DefaultGroup != "" || inc.Classify || inc.ClassifyThreshold != nil ||
+				inc.Envelope || inc.TransferDays != 0 || inc.Alert != (Alert{}) || inc.Forecast != (Forecast{}) || len(inc.Recurring) > 0 || len(inc.Amortize) > 0 || len(inc.Goals) > 0 {
+				err := fmt.Errorf("only accounts, accountGroups, groups and include can be set in an included file")
+				if err := r.fail(position{file: m}, err); err != nil {
+					return Config{}, false, err
+				}
+			}
+			conf.Accounts = append(conf.Accounts, inc.Accounts...)
+			conf.AccountGroups = append(conf.AccountGroups, inc.AccountGroups...)
+			conf.Groups = append(conf.Groups, inc.Groups...)
+		}
+	}
+	return conf, true, nil
+}
+
+// locate sets the source of accounts, account groups, groups and goals declared as array tables in data, read from file
+// name.
+func (c *Config) locate(name, data string) {
+	var accounts, accountGroups, groups, goals []int
+	for i, line := range strings.Split(data, "\n") {
+		m := tableHeader.FindStringSubmatch(line)
+		if m == nil || !strings.HasPrefix(strings.TrimSpace(line), "[[") {
+			continue
+		}
+		switch strings.ToLower(m[1]) {
+		case "accounts":
+			accounts = append(accounts, i+1)
+		case "accountgroups":
+			accountGroups = append(accountGroups, i+1)
+		case "groups":
+			groups = append(groups, i+1)
+		case "goals":
+			goals = append(goals, i+1)
+		}
+	}
+	source := func(lines []int, n, i int) position {
+		if len(lines) != n {
+			return position{file: name} // Tables are declared inline, so their line is unknown
+		}
+		return position{file: name, line: lines[i]}
+	}
+	for i := range c.Accounts {
+		c.Accounts[i].source = source(accounts, len(c.Accounts), i)
+	}
+	for i := range c.AccountGroups {
+		c.AccountGroups[i].source = source(accountGroups, len(c.AccountGroups), i)
+	}
+	for i := range c.Groups {
+		c.Groups[i].source = source(groups, len(c.Groups), i)
+	}
+	for i := range c.Goals {
+		c.Goals[i].source = source(goals, len(c.Goals), i)
+	}
+}
+
 func readerFrom(r io.Reader, name, filename string) (record.Reader, error) {
 	var rr record.Reader
 	switch name {
@@ -144,22 +708,31 @@ func readerFrom(r io.Reader, name, filename string) (record.Reader, error) {
 
 // FromConfig creates a new journal from a configuration file located at name.
 func FromConfig(name string) (*Journal, error) {
-	if name == "~/.journalrc" {
-		home := os.Getenv("HOME")
-		name = filepath.Join(home, ".journalrc")
-	}
-	f, err := os.Open(name)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-	conf, err := readConfig(f)
+	conf, err := readConfigFile(configPath(name))
 	if err != nil {
 		return nil, err
 	}
 	return New(conf)
 }
 
+// configPath returns the path of configuration file name. If name is the default, ~/.journalrc, the XDG configuration
+// file, $XDG_CONFIG_HOME/journal/config.toml, is preferred if it exists.
+func configPath(name string) string {
+	if name != "~/.journalrc" {
+		return name
+	}
+	home := os.Getenv("HOME")
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		configHome = filepath.Join(home, ".config")
+	}
+	xdg := filepath.Join(configHome, "journal", "config.toml")
+	if _, err := os.Stat(xdg); err == nil {
+		return xdg
+	}
+	return filepath.Join(home, ".journalrc")
+}
+
 // New creates a new journal from the given configuration.
 func New(conf Config) (*Journal, error) {
 	if err := conf.load(); err != nil {
@@ -177,14 +750,36 @@ func New(conf Config) (*Journal, error) {
 	if defaultGroup == "" {
 		defaultGroup = "* ungrouped *"
 	}
-	return &Journal{
-		db:           db,
-		accounts:     conf.Accounts,
-		groups:       conf.Groups,
-		Comma:        comma,
-		DefaultGroup: defaultGroup,
-		Discarding:   true,
-	}, nil
+	threshold := 0.8
+	if conf.ClassifyThreshold != nil {
+		threshold = *conf.ClassifyThreshold
+	}
+	transferDays := conf.TransferDays
+	if transferDays == 0 {
+		transferDays = defaultTransferDays
+	}
+	j := &Journal{
+		db:                db,
+		accounts:          conf.Accounts,
+		accountGroups:     conf.AccountGroups,
+		configGroups:      conf.Groups,
+		classifying:       conf.Classify,
+		classifyThreshold: threshold,
+		envelope:          conf.Envelope,
+		alert:             conf.Alert,
+		forecast:          conf.Forecast,
+		recurring:         conf.Recurring,
+		transferDays:      transferDays,
+		goals:             conf.Goals,
+		Comma:             comma,
+		DefaultGroup:      defaultGroup,
+		Discarding:        true,
+	}
+	if err := j.loadStoredGroups(); err != nil {
+		return nil, err
+	}
+	j.loadAmortize(conf.Amortize)
+	return j, nil
 }
 
 // FormatAmount formats number n as a financial amount.
@@ -213,30 +808,90 @@ func (j *Journal) ReadFile(reader string, f *os.File) ([]record.Record, error) {
 	return r.Read()
 }
 
-// Export writes periods to writer w using CSV-encoding. The timeLayout defines the format of time fields.
-func (j *Journal) Export(w io.Writer, periods []record.Period, timeLayout string) error {
+// Export writes periods to writer w using CSV-encoding. The timeLayout defines the format of time fields. Groups nested
+// deeper than depth are merged into their ancestor at depth. If depth is zero, groups are written at all levels. The sum
+// of a group includes the records of its nested groups, like in Rollup.
+func (j *Journal) Export(w io.Writer, periods []record.Period, timeLayout string, depth int) error {
 	csv := csv.NewWriter(w)
 	for _, p := range periods {
-		for _, rg := range p.Groups {
-			r := []string{p.Time.Format(timeLayout), rg.Name, j.FormatAmount(rg.Sum())}
-			if err := csv.Write(r); err != nil {
-				return err
+		var write func(gs []record.Group) error
+		write = func(gs []record.Group) error {
+			for _, rg := range gs {
+				r := []string{p.Time.Format(timeLayout), rg.Name, j.FormatAmount(rg.Sum())}
+				if err := csv.Write(r); err != nil {
+					return err
+				}
+				if err := write(rg.Children); err != nil {
+					return err
+				}
 			}
+			return nil
+		}
+		if err := write(j.Rollup(record.Collapse(p.Groups, depth, j.newGroup))); err != nil {
+			return err
 		}
 	}
 	csv.Flush()
 	return csv.Error()
 }
 
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
+
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func recordAccount(a sql.Account) record.Account {
+	return record.Account{
+		Number:         a.Number,
+		Name:           a.Name,
+		Type:           a.Type,
+		Institution:    a.Institution,
+		OpeningBalance: a.OpeningBalance,
+		OpeningTime:    unixTime(a.OpeningTime),
+		Closed:         a.Closed,
+	}
+}
+
+func (a *Account) record() record.Account {
+	return record.Account{
+		Number:         a.Number,
+		Name:           a.Name,
+		Type:           a.Type,
+		Institution:    a.Institution,
+		OpeningBalance: a.OpeningBalance,
+		OpeningTime:    a.openingTime,
+		Closed:         a.Closed,
+	}
+}
+
 func (j *Journal) writeAccounts() (int64, error) {
 	as := make([]sql.Account, len(j.accounts))
 	for i, a := range j.accounts {
-		as[i] = sql.Account{Number: a.Number, Name: a.Name}
+		as[i] = sql.Account{
+			Number:         a.Number,
+			Name:           a.Name,
+			Type:           a.Type,
+			Institution:    a.Institution,
+			OpeningBalance: a.OpeningBalance,
+			OpeningTime:    unixSeconds(a.openingTime),
+			Closed:         a.Closed,
+		}
 	}
 	return j.db.AddAccounts(as)
 }
 
-// Accounts returns all accounts in the journal
+// Accounts returns all accounts in the journal. Metadata of accounts declared in the configuration is written to the
+// journal on import. The balance of each account is its opening balance plus the sum of records since its opening
+// date.
 func (j *Journal) Accounts() ([]record.Account, error) {
 	as, err := j.db.SelectAccounts("")
 	if err != nil {
@@ -244,15 +899,80 @@ func (j *Journal) Accounts() ([]record.Account, error) {
 	}
 	accounts := make([]record.Account, len(as))
 	for i, a := range as {
-		accounts[i] = record.Account{
-			Name:    a.Name,
-			Number:  a.Number,
-			Records: a.Records,
-		}
+		accounts[i] = recordAccount(a)
+		accounts[i].Records = a.Records
+		accounts[i].Balance = a.OpeningBalance + a.Sum
 	}
 	return accounts, nil
 }
 
+// UnknownAccounts returns accounts that exist in the journal, but are not declared in the configuration.
+func (j *Journal) UnknownAccounts() ([]record.Account, error) {
+	as, err := j.Accounts()
+	if err != nil {
+		return nil, err
+	}
+	var unknown []record.Account
+	for _, a := range as {
+		if _, ok := j.account(a.Number); !ok {
+			unknown = append(unknown, a)
+		}
+	}
+	return unknown, nil
+}
+
+func (j *Journal) account(number string) (Account, bool) {
+	for _, a := range j.accounts {
+		if a.Number == number {
+			return a, true
+		}
+	}
+	return Account{}, false
+}
+
+// RenameAccount changes the name of the account identified by number. If name is empty, the name declared in the
+// configuration is used.
+func (j *Journal) RenameAccount(number, name string) error {
+	if name == "" {
+		a, ok := j.account(number)
+		if !ok {
+			return fmt.Errorf("account %s is not declared in config", number)
+		}
+		name = a.Name
+	}
+	n, err := j.db.RenameAccount(number, name)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("invalid account: %s", number)
+	}
+	return nil
+}
+
+// RemoveAccount removes the account identified by number from the journal. Removing an account that has records is
+// an error, unless purge is true, in which case its records are removed too. The number of removed records is
+// returned.
+func (j *Journal) RemoveAccount(number string, purge bool) (int64, error) {
+	as, err := j.db.SelectAccounts(number)
+	if err != nil {
+		return 0, err
+	}
+	if len(as) == 0 {
+		return 0, fmt.Errorf("invalid account: %s", number)
+	}
+	if as[0].Records > 0 && !purge {
+		return 0, fmt.Errorf("account %s has %d record(s)", number, as[0].Records)
+	}
+	return j.db.DeleteAccount(number)
+}
+
+// MergeAccounts moves all records from account fromNumber to account toNumber and removes account fromNumber. The
+// number of moved records is returned.
+func (j *Journal) MergeAccounts(fromNumber, toNumber string) (int64, error) {
+	return j.db.MergeAccounts(fromNumber, toNumber)
+}
+
 // Write writes records for accountNumber into the journal.
 func (j *Journal) Write(accountNumber string, records []record.Record) (Writes, error) {
 	var writes Writes
@@ -261,80 +981,264 @@ func (j *Journal) Write(accountNumber string, records []record.Record) (Writes,
 		return writes, err
 	}
 	writes.Account = n
+	last, err := j.db.LastRecordID()
+	if err != nil {
+		return writes, err
+	}
 	rs := make([]sql.Record, len(records))
+	var since, until time.Time
 	for i, r := range records {
 		rs[i] = sql.Record{Time: r.Time.Unix(), Text: r.Text, Amount: r.Amount, Balance: r.Balance}
+		if since.IsZero() || r.Time.Before(since) {
+			since = r.Time
+		}
+		if r.Time.After(until) {
+			until = r.Time
+		}
 	}
 	n, err = j.db.AddRecords(accountNumber, rs)
 	writes.Record = n
+	if err != nil || n == 0 {
+		return writes, err
+	}
+	writes.Transfer, err = j.detectTransfers(last, since, until)
 	return writes, err
 }
 
-// Read reads records for accountNumber between the times since and until from the journal.
-func (j *Journal) Read(accountNumber string, since, until time.Time) ([]record.Record, error) {
-	rs, err := j.db.SelectRecordsBetween(accountNumber, since, until)
+func (j *Journal) expandAccounts(names []string) []string {
+	var numbers []string
+	for _, name := range names {
+		expanded := false
+		for _, ag := range j.accountGroups {
+			if ag.Name == name {
+				numbers = append(numbers, ag.Accounts...)
+				expanded = true
+				break
+			}
+		}
+		if !expanded {
+			numbers = append(numbers, name)
+		}
+	}
+	return numbers
+}
+
+// ResolveAccounts resolves the account numbers and account group names in include and exclude into a list of account
+// numbers. If include is empty, all accounts in the journal are included. A nil slice is returned if all accounts are
+// included and none are excluded.
+func (j *Journal) ResolveAccounts(include, exclude []string) ([]string, error) {
+	numbers := j.expandAccounts(include)
+	excluded := j.expandAccounts(exclude)
+	if len(excluded) == 0 {
+		return numbers, nil
+	}
+	if len(numbers) == 0 {
+		as, err := j.db.SelectAccounts("")
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range as {
+			numbers = append(numbers, a.Number)
+		}
+	}
+	var resolved []string
+	for _, n := range numbers {
+		keep := true
+		for _, e := range excluded {
+			if n == e {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			resolved = append(resolved, n)
+		}
+	}
+	if len(resolved) == 0 {
+		return nil, fmt.Errorf("all accounts are excluded")
+	}
+	return resolved, nil
+}
+
+// FindRecord returns the record identified by id.
+func (j *Journal) FindRecord(id string) (record.Record, bool, error) {
+	rs, err := j.Read(nil, time.Time{}, time.Time{})
+	if err != nil {
+		return record.Record{}, false, err
+	}
+	for _, r := range rs {
+		if r.ID() == id {
+			return r, true, nil
+		}
+	}
+	return record.Record{}, false, nil
+}
+
+// Read reads records for accountNumbers between the times since and until from the journal. If accountNumbers is
+// empty, records for all accounts are read.
+func (j *Journal) Read(accountNumbers []string, since, until time.Time) ([]record.Record, error) {
+	rs, err := j.db.SelectRecordsBetween(accountNumbers, since, until)
 	if err != nil {
 		return nil, err
 	}
 	records := make([]record.Record, len(rs))
 	for i, r := range rs {
-		records[i] = record.Record{
-			Account: record.Account{Number: r.Account.Number, Name: r.Account.Name},
-			Time:    time.Unix(r.Time, 0).UTC(),
-			Text:    r.Text,
-			Amount:  r.Amount,
-		}
+		records[i] = newRecord(r)
 	}
 	return records, nil
 }
 
+func newRecord(r sql.Record) record.Record {
+	return record.Record{
+		Account:  recordAccount(r.Account),
+		Time:     time.Unix(r.Time, 0).UTC(),
+		Text:     r.Text,
+		Amount:   r.Amount,
+		Transfer: r.Transfer,
+	}
+}
+
 // Assort assorts records into groups using this journal's configuration.
 func (j *Journal) Assort(records []record.Record) []record.Group {
 	return record.AssortFunc(records, j.findGroup)
 }
 
+// Rollup arranges groups into a hierarchy using this journal's configuration. See record.Rollup.
+func (j *Journal) Rollup(gs []record.Group) []record.Group { return record.Rollup(gs, j.newGroup) }
+
 // AssortPeriod assorts record groups into time periods using timeFn.
 func (j *Journal) AssortPeriod(records []record.Record, timeFn func(time.Time) time.Time) []record.Period {
 	return record.AssortPeriodFunc(records, timeFn, j.findGroup)
 }
 
-func (j *Journal) findGroup(r record.Record) *record.Group {
+func recordGroup(g Group) record.Group {
+	budget := record.Budget{Default: g.Budget, Period: g.BudgetPeriod}
+	copy(budget.Months[:], g.Budgets)
+	for _, p := range g.BudgetHistory {
+		period := record.BudgetPeriod{Since: p.since, Default: p.Budget}
+		copy(period.Months[:], p.Budgets)
+		budget.Periods = append(budget.Periods, period)
+	}
+	return record.NewGroup(g.Name, budget)
+}
+
+// GroupNames returns the names of configured groups, in order of precedence.
+func (j *Journal) GroupNames() []string {
+	var names []string
+	seen := make(map[string]bool)
 	for _, g := range j.groups {
+		if !seen[g.Name] {
+			names = append(names, g.Name)
+			seen[g.Name] = true
+		}
+	}
+	return names
+}
+
+func (j *Journal) configGroup(name string) *Group {
+	for i := range j.groups {
+		if j.groups[i].Name == name {
+			return &j.groups[i]
+		}
+	}
+	return nil
+}
+
+func (j *Journal) newGroup(name string) record.Group {
+	if g := j.configGroup(name); g != nil {
+		return recordGroup(*g)
+	}
+	return record.Group{Name: name}
+}
+
+// visit calls fn for each pin and rule evaluated when assorting record r, in order of precedence. Pins are only
+// visited if they match r, and have a nil rule. Visiting stops when fn returns false.
+func (j *Journal) visit(r record.Record, fn func(g *Group, rule *Rule, matched bool) bool) {
+	r = r.Original() // Slices of an amortized record are assorted like the record itself
+	id := r.ID()
+	for i := range j.groups {
+		g := &j.groups[i]
 		if g.Account != "" && g.Account != r.Account.Number {
 			continue
 		}
-		for _, id := range g.IDs {
-			if r.ID() != id {
-				continue
-			}
-			if j.Discarding && g.Discard {
-				return nil
-			}
-			rg := record.NewGroup(g.Name, record.Budget{
-				Default: g.Budget,
-				Months:  g.Budgets,
-			})
-			return &rg
-
+		if slices.Contains(g.IDs, id) && !fn(g, nil, true) {
+			return
 		}
 	}
-	for _, g := range j.groups {
+	for i := range j.groups {
+		g := &j.groups[i]
 		if g.Account != "" && g.Account != r.Account.Number {
 			continue
 		}
-		for _, p := range g.patterns {
-			if !p.MatchString(r.Text) {
-				continue
-			}
-			if j.Discarding && g.Discard {
-				return nil
+		for k := range g.rules {
+			rule := &g.rules[k]
+			if !fn(g, rule, rule.match(r)) {
+				return
 			}
-			rg := record.NewGroup(g.Name, record.Budget{
-				Default: g.Budget,
-				Months:  g.Budgets,
-			})
-			return &rg
 		}
 	}
-	return &record.Group{Name: j.DefaultGroup}
+}
+
+// matchGroup returns the group of the first pin or rule matching record r, or nil if none match.
+func (j *Journal) matchGroup(r record.Record) *Group {
+	var group *Group
+	j.visit(r, func(g *Group, rule *Rule, matched bool) bool {
+		if matched {
+			group = g
+		}
+		return !matched
+	})
+	return group
+}
+
+func (j *Journal) findGroup(r record.Record) *record.Group {
+	group := j.matchGroup(r)
+	if group == nil {
+		_, group, _ = j.classify(r)
+	}
+	if group == nil {
+		return &record.Group{Name: j.DefaultGroup}
+	}
+	if j.Discarding && group.Discard {
+		return nil
+	}
+	rg := recordGroup(*group)
+	return &rg
+}
+
+// Explain returns the pins and rules evaluated when assorting record r, in order of precedence. The first matching
+// entry decides the group of r. Any later matching entries are shadowed by the first one. If no entry matches and the
+// classifier is enabled, its proposal is included last. If nothing matches, r belongs to the default group.
+func (j *Journal) Explain(r record.Record) []Match {
+	var ms []Match
+	found := false
+	j.visit(r, func(g *Group, rule *Rule, matched bool) bool {
+		ms = append(ms, newMatch(g, rule, r, matched))
+		found = found || matched
+		return true
+	})
+	if !found {
+		if m, _, ok := j.classify(r); ok {
+			ms = append(ms, m)
+		}
+	}
+	return ms
+}
+
+// MatchOf returns the pin, rule or classification that decides the group of record r. It returns false if r belongs
+// to the default group.
+func (j *Journal) MatchOf(r record.Record) (Match, bool) {
+	var m Match
+	found := false
+	j.visit(r, func(g *Group, rule *Rule, matched bool) bool {
+		if matched {
+			m = newMatch(g, rule, r, matched)
+			found = true
+		}
+		return !matched
+	})
+	if !found {
+		m, _, found = j.classify(r)
+	}
+	return m, found
 }
@@ -1,6 +1,8 @@
 package journal
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -11,6 +13,7 @@ import (
 	"github.com/mpolden/journal/record/dnb"
 	"github.com/mpolden/journal/record/komplett"
 	"github.com/mpolden/journal/record/norwegian"
+	"github.com/mpolden/journal/sql"
 )
 
 func date(year int, month time.Month, day int) time.Time {
@@ -172,7 +175,7 @@ func TestAssort(t *testing.T) {
 	if _, err := j.Write(a2.Number, rs[6:]); err != nil {
 		t.Fatal(err)
 	}
-	records, err := j.Read("", time.Time{}, time.Time{})
+	records, err := j.Read(nil, time.Time{}, time.Time{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -196,3 +199,723 @@ func TestAssort(t *testing.T) {
 		}
 	}
 }
+
+func TestAccountManagement(t *testing.T) {
+	j := testJournal(t)
+	rs := []record.Record{{Time: date(2018, 1, 1), Text: "Transaction 1", Amount: 42}}
+	if _, err := j.Write("1234.56.78900", rs); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rename to explicit name, and back to configured name
+	if err := j.RenameAccount("1234.56.78900", "Renamed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.RenameAccount("1234.56.78901", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.RenameAccount("1234.56.78902", ""); err == nil {
+		t.Error("want error when renaming undeclared account")
+	}
+	as, err := j.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "Renamed", as[0].Name; want != got {
+		t.Errorf("want Name = %q, got %q", want, got)
+	}
+
+	// Accounts with records are not removed unless purging
+	if _, err := j.RemoveAccount("1234.56.78900", false); err == nil {
+		t.Error("want error when removing account with records")
+	}
+	if _, err := j.MergeAccounts("1234.56.78900", "1234.56.78901"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.RemoveAccount("1234.56.78900", false); err == nil {
+		t.Error("want error when removing merged account")
+	}
+	n, err := j.RemoveAccount("1234.56.78901", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(1); n != want {
+		t.Errorf("want %d removed records, got %d", want, n)
+	}
+
+	// Reading accounts does not restore removed accounts
+	as, err = j.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range as {
+		if a.Number == "1234.56.78900" || a.Number == "1234.56.78901" {
+			t.Errorf("want account %s to stay removed", a.Number)
+		}
+	}
+}
+
+func TestUnknownAccounts(t *testing.T) {
+	j := testJournal(t)
+	if _, err := j.db.AddAccounts([]sql.Account{{Number: "1.2.3", Name: "Old account"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("1234.56.78900", nil); err != nil {
+		t.Fatal(err)
+	}
+	as, err := j.UnknownAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 || as[0].Number != "1.2.3" {
+		t.Errorf("want unknown account 1.2.3, got %+v", as)
+	}
+}
+
+func TestAccountMetadata(t *testing.T) {
+	tomlConf := `
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+type = "checking"
+institution = "Example Bank"
+openingBalance = 10000
+openingDate = "2018-01-01"
+
+[[accounts]]
+number = "4.5.6"
+name = "Credit card"
+type = "creditcard"
+closed = true
+`
+	conf, err := readConfig(strings.NewReader(tomlConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := []record.Record{
+		{Time: date(2017, 12, 31), Text: "Before opening", Amount: 500},
+		{Time: date(2018, 1, 1), Text: "Transaction 1", Amount: -2000},
+	}
+	if _, err := j.Write("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	as, err := j.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 2 {
+		t.Fatalf("want 2 accounts, got %d", len(as))
+	}
+	if want, got := int64(8000), as[0].Balance; want != got {
+		t.Errorf("want Balance = %d, got %d", want, got)
+	}
+	if want, got := "Example Bank", as[0].Institution; want != got {
+		t.Errorf("want Institution = %q, got %q", want, got)
+	}
+	if as[0].Liability() {
+		t.Errorf("want %s to be an asset", as[0].Number)
+	}
+	if !as[1].Liability() || !as[1].Closed {
+		t.Errorf("want %s to be a closed liability", as[1].Number)
+	}
+	records, err := j.Read([]string{"1.2.3"}, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := date(2018, 1, 1), records[0].Account.OpeningTime; !want.Equal(got) {
+		t.Errorf("want OpeningTime = %s, got %s", want, got)
+	}
+
+	conf.Accounts[0].Type = "foo"
+	if _, err := New(conf); err == nil {
+		t.Error("want error for invalid account type")
+	}
+}
+
+func testAccountGroupJournal(t *testing.T) *Journal {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+
+[[accounts]]
+number = "1234.56.78901"
+name = "My account 2"
+
+[[accounts]]
+number = "1234.56.78902"
+name = "My account 3"
+
+[[accountGroups]]
+name = "household"
+accounts = ["1234.56.78900", "1234.56.78901"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestGroupParent(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[groups]]
+name = "Groceries"
+parent = "Food"
+patterns = ["^Rema"]
+
+[[groups]]
+name = "Food/Restaurants"
+parent = "Food"
+patterns = ["^Pizza"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		j, err := New(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want, got := []string{"Food/Groceries", "Food/Restaurants"}, j.GroupNames(); !reflect.DeepEqual(want, got) {
+			t.Errorf("#%d: want groups %q, got %q", i, want, got)
+		}
+	}
+	if want, got := "Groceries", conf.Groups[0].Name; want != got {
+		t.Errorf("want name %q in config, got %q", want, got)
+	}
+	if err := conf.load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.load(); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "Food/Groceries", conf.Groups[0].Name; want != got {
+		t.Errorf("want name %q after loading twice, got %q", want, got)
+	}
+}
+
+func TestResolveAccounts(t *testing.T) {
+	j := testAccountGroupJournal(t)
+	if _, err := j.Write("1234.56.78900", nil); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		include []string
+		exclude []string
+		out     []string
+		err     bool
+	}{
+		{nil, nil, nil, false},
+		{[]string{"1234.56.78900"}, nil, []string{"1234.56.78900"}, false},
+		{[]string{"household"}, nil, []string{"1234.56.78900", "1234.56.78901"}, false},
+		{[]string{"household", "1234.56.78902"}, nil, []string{"1234.56.78900", "1234.56.78901", "1234.56.78902"}, false},
+		{[]string{"household"}, []string{"1234.56.78901"}, []string{"1234.56.78900"}, false},
+		{nil, []string{"household"}, []string{"1234.56.78902"}, false},
+		{[]string{"household"}, []string{"household"}, nil, true},
+	}
+	for i, tt := range tests {
+		out, err := j.ResolveAccounts(tt.include, tt.exclude)
+		if tt.err != (err != nil) {
+			t.Errorf("#%d: want error = %t, got %v", i, tt.err, err)
+		}
+		if !reflect.DeepEqual(out, tt.out) {
+			t.Errorf("#%d: want %q, got %q", i, tt.out, out)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	tomlConf := `
+Database = ":memory:"
+DefaultGroup = "* no group *"
+
+[[accounts]]
+number = "1.2.3"
+name = "My account"
+
+[[groups]]
+name = "Rent"
+
+  [[groups.rules]]
+  pattern = "^Vipps"
+  maxAmount = -500000
+
+[[groups]]
+name = "Old groceries"
+
+  [[groups.rules]]
+  pattern = "^Rema"
+  until = "2022-12-31"
+
+[[groups]]
+name = "Groceries"
+patterns = ["^Rema"]
+
+[[groups]]
+name = "Salary"
+
+  [[groups.rules]]
+  sign = "income"
+  daysOfMonth = [20]
+
+[[groups]]
+name = "Weekend"
+
+  [[groups.rules]]
+  weekdays = ["Saturday", "sunday"]
+  sign = "expense"
+
+  [[groups.rules]]
+  pattern = "^Pub"
+  minAmount = -10000
+`
+	conf, err := readConfig(strings.NewReader(tomlConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := record.Account{Number: "1.2.3"}
+	var tests = []struct {
+		r     record.Record
+		group string
+	}{
+		{record.Record{Account: a, Time: date(2024, 1, 2), Text: "Vipps", Amount: -600000}, "Rent"},
+		{record.Record{Account: a, Time: date(2024, 1, 2), Text: "Vipps", Amount: -500000}, "Rent"},
+		{record.Record{Account: a, Time: date(2024, 1, 2), Text: "Vipps", Amount: -20000}, "* no group *"},
+		{record.Record{Account: a, Time: date(2022, 12, 31), Text: "Rema 1000", Amount: -100}, "Old groceries"},
+		{record.Record{Account: a, Time: date(2023, 1, 2), Text: "Rema 1000", Amount: -100}, "Groceries"},
+		{record.Record{Account: a, Time: date(2024, 1, 20), Text: "Employer", Amount: 100}, "Salary"},
+		{record.Record{Account: a, Time: date(2024, 1, 20), Text: "Refund", Amount: -100}, "Weekend"}, // Saturday
+		{record.Record{Account: a, Time: date(2024, 1, 22), Text: "Pub", Amount: -5000}, "Weekend"},   // Monday
+		{record.Record{Account: a, Time: date(2024, 1, 22), Text: "Pub", Amount: -50000}, "* no group *"},
+	}
+	for i, tt := range tests {
+		g := j.findGroup(tt.r)
+		if g == nil {
+			t.Fatalf("#%d: want group %q, got nil", i, tt.group)
+		}
+		if g.Name != tt.group {
+			t.Errorf("#%d: want group %q, got %q", i, tt.group, g.Name)
+		}
+	}
+
+	one, two := int64(1), int64(2)
+	var invalid = []Rule{
+		{},
+		{Pattern: "("},
+		{Sign: "foo"},
+		{Since: "2024-13-01"},
+		{Weekdays: []string{"caturday"}},
+		{DaysOfMonth: []int{32}},
+		{MinAmount: &two, MaxAmount: &one},
+	}
+	for i, rule := range invalid {
+		if err := rule.load(); err == nil {
+			t.Errorf("#%d: want error for rule %+v", i, rule)
+		}
+	}
+}
+
+func TestExplain(t *testing.T) {
+	j := testJournal(t)
+	a1 := record.Account{Number: "1234.56.78900", Name: "My account 1"}
+	r := record.Record{Account: a1, Time: date(2018, 1, 1), Text: "Bar 2", Amount: 42} // Pinned to Misc
+	want := []Match{
+		{Group: "Misc", Rule: "45defdf469", Pinned: true, Matched: true},
+		{Group: "Travel", Rule: "^Foo"},
+		{Group: "Groceries", Rule: "^Bar", Matched: true},
+		{Group: "Groceries", Rule: "^Baz"},
+		{Group: "Unimportant", Rule: "^Spam", Discard: true},
+	}
+	if got := j.Explain(r); !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	if m, ok := j.MatchOf(r); !ok || !reflect.DeepEqual(want[0], m) {
+		t.Errorf("want %+v, got %+v", want[0], m)
+	}
+	r.Text = "Qux"
+	if m, ok := j.MatchOf(r); ok {
+		t.Errorf("want no match, got %+v", m)
+	}
+	rule := Rule{Pattern: "^Vipps", Sign: "expense", DaysOfMonth: []int{1, 15}}
+	if want, got := `pattern = "^Vipps", sign = "expense", daysOfMonth = [1 15]`, rule.String(); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"journalrc": `
+Database = ":memory:"
+include = ["accounts.toml", "groups/*.toml"]
+
+[[groups]]
+name = "Main"
+patterns = ["^Main"]
+`,
+		"accounts.toml": `
+[[accounts]]
+number = "1234.56.78900"
+name = "My account 1"
+`,
+		"groups/b.toml": `
+[[groups]]
+name = "B"
+patterns = ["^B"]
+`,
+		"groups/a.toml": `
+include = ["nested/*.toml"]
+
+[[groups]]
+name = "A1"
+patterns = ["^A"]
+
+[[groups]]
+name = "A2"
+patterns = ["^A"]
+`,
+		"groups/nested/c.toml": `
+[[groups]]
+name = "C"
+patterns = ["^C"]
+`,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conf, err := readConfigFile(filepath.Join(dir, "journalrc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, g := range conf.Groups {
+		names = append(names, g.Name)
+	}
+	if want := []string{"Main", "A1", "A2", "C", "B"}; !reflect.DeepEqual(want, names) {
+		t.Errorf("want groups %q, got %q", want, names)
+	}
+	if want, got := 1, len(conf.Accounts); want != got {
+		t.Errorf("want %d accounts, got %d", want, got)
+	}
+	if want, got := filepath.Join(dir, "groups", "a.toml")+":8", conf.Groups[2].source.String(); want != got {
+		t.Errorf("want source %q, got %q", want, got)
+	}
+
+	var tests = []struct {
+		file string
+		data string
+		err  string
+	}{
+		{"groups/b.toml", "[[groups]]\nname = \"B\"\npatterns = [\"(\"]\n", filepath.Join(dir, "groups", "b.toml") + ":1: group: \"B\": patterns[0]: error parsing regexp"},
+		{"groups/b.toml", "Database = \"other.db\"\n", filepath.Join(dir, "groups", "b.toml") + ": only accounts"},
+		{"groups/b.toml", "include = [\"../journalrc\"]\n", "include cycle"},
+		{"groups/b.toml", "[[groups]\n", filepath.Join(dir, "groups", "b.toml") + ": toml: line "},
+		{"accounts.toml", "[[accounts]]\nnumber = \"1\"\n\n[[accounts]]\nnumber = \"2\"\ntype = \"foo\"\n", filepath.Join(dir, "accounts.toml") + ":4: account: \"2\": invalid type"},
+		{"journalrc", "Database = \":memory:\"\ninclude = [\"missing.toml\"]\n", "included file does not exist"},
+	}
+	for i, tt := range tests {
+		path := filepath.Join(dir, tt.file)
+		if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := FromConfig(filepath.Join(dir, "journalrc"))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("#%d: want error containing %q, got %v", i, tt.err, err)
+		}
+		if err := os.WriteFile(path, []byte(files[tt.file]), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestConfigPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	if want, got := filepath.Join(home, ".journalrc"), configPath("~/.journalrc"); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	xdg := filepath.Join(home, ".config", "journal", "config.toml")
+	if err := os.MkdirAll(filepath.Dir(xdg), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(xdg, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if want, got := xdg, configPath("~/.journalrc"); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, "other"))
+	if want, got := filepath.Join(home, ".journalrc"), configPath("~/.journalrc"); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if want, got := "/etc/journalrc", configPath("/etc/journalrc"); want != got {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestCarry(t *testing.T) {
+	tomlConf := `
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[groups]]
+name = "Clothing"
+budget = -1000
+rollover = true
+
+  [[groups.rules]]
+  pattern = "^Clothes"
+
+[[groups]]
+name = "Groceries"
+budget = -1000
+patterns = ["^Food"]
+
+[[groups]]
+name = "Home"
+budget = -500
+rollover = true
+
+[[groups]]
+name = "Repairs"
+parent = "Home"
+patterns = ["^Repair"]
+`
+	conf, err := readConfig(strings.NewReader(tomlConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := []record.Record{
+		{Time: date(2018, 1, 15), Text: "Clothes 1", Amount: -400},  // Surplus of 600
+		{Time: date(2018, 3, 15), Text: "Clothes 2", Amount: -3000}, // No records in February. Deficit of 400
+		{Time: date(2018, 4, 10), Text: "Clothes 3", Amount: -100},
+		{Time: date(2018, 1, 15), Text: "Food 1", Amount: -400},
+		{Time: date(2018, 1, 20), Text: "Repair 1", Amount: -200},
+	}
+	if _, err := j.Write("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	if !j.HasRollover() {
+		t.Error("want rollover")
+	}
+	var tests = []struct {
+		since   time.Time
+		carried int64
+	}{
+		{date(2018, 1, 1), 0},
+		{date(2018, 2, 1), -600},
+		{date(2018, 3, 1), -1600},
+		{date(2018, 4, 1), 400},
+		{date(2018, 4, 20), 400},
+		{date(2018, 5, 1), -500},
+	}
+	for i, tt := range tests {
+		gs := []record.Group{{Name: "Clothing"}, {Name: "Groceries"}}
+		if err := j.Carry(gs, nil, tt.since); err != nil {
+			t.Fatal(err)
+		}
+		if got := gs[0].Carry; got != tt.carried {
+			t.Errorf("#%d: want carried %d, got %d", i, tt.carried, got)
+		}
+		if got := gs[1].Carry; got != 0 {
+			t.Errorf("#%d: want no carry for group without rollover, got %d", i, got)
+		}
+	}
+
+	// Rollover of a parent group applies to the rolled up group
+	gs := j.Rollup([]record.Group{{Name: "Home/Repairs"}})
+	if err := j.Carry(gs, nil, date(2018, 2, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := gs[0].Carried(), int64(-300); got != want {
+		t.Errorf("want carried %d in %s, got %d", want, gs[0].Name, got)
+	}
+}
+
+func TestBudgetHistory(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[groups]]
+name = "Groceries"
+budget = -500000
+
+[[groups.budgetHistory]]
+since = "2024-01-01"
+budget = -600000
+
+[[groups.budgetHistory]]
+since = "2025-01-01"
+budgets = [-700000, -700000, -700000, -700000, -700000, -700000, -700000, -700000, -700000, -700000, -700000, -800000]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.load(); err != nil {
+		t.Fatal(err)
+	}
+	g := recordGroup(conf.Groups[0])
+	var tests = []struct {
+		since, until time.Time
+		budget       int64
+	}{
+		{date(2023, 12, 1), date(2023, 12, 31), -500000},
+		{date(2024, 1, 1), date(2024, 1, 31), -600000},
+		{date(2023, 11, 1), date(2024, 2, 29), -500000*2 + -600000*2},
+		{date(2025, 12, 1), date(2025, 12, 31), -800000},
+	}
+	for i, tt := range tests {
+		if got := g.Budget(record.Range{Since: tt.since, Until: tt.until}); got != tt.budget {
+			t.Errorf("#%d: want budget %d, got %d", i, tt.budget, got)
+		}
+	}
+
+	var invalid = []struct {
+		history string
+		err     string
+	}{
+		{"since = \"2024\"", `group: "Groceries": budgetHistory[0]: invalid since date: "2024"`},
+		{"since = \"2024-01-01\"\nbudgets = [1]", `group: "Groceries": budgetHistory[0]: budgets must have 12 values, got 1`},
+		{"since = \"2024-01-01\"\n[[groups.budgetHistory]]\nsince = \"2023-01-01\"", `group: "Groceries": budgetHistory[1]: since must be later than 2024-01-01`},
+	}
+	for i, tt := range invalid {
+		conf, err := readConfig(strings.NewReader("Database = \":memory:\"\n[[groups]]\nname = \"Groceries\"\n[[groups.budgetHistory]]\n" + tt.history))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conf.load(); err == nil || err.Error() != tt.err {
+			t.Errorf("#%d: want error %q, got %v", i, tt.err, err)
+		}
+	}
+}
+
+func TestSpend(t *testing.T) {
+	tomlConf := `
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[groups]]
+name = "Insurance"
+budget = -12000
+budgetPeriod = "year"
+patterns = ["^Insurance"]
+
+[[groups]]
+name = "Groceries"
+budget = -1000
+patterns = ["^Food"]
+
+[[groups]]
+name = "Bills"
+budget = -24000
+budgetPeriod = "year"
+
+[[groups]]
+name = "Power"
+parent = "Bills"
+budget = -6000
+budgetPeriod = "quarter"
+patterns = ["^Power"]
+`
+	conf, err := readConfig(strings.NewReader(tomlConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := []record.Record{
+		{Time: date(2017, 12, 15), Text: "Insurance 1", Amount: -5000}, // Previous year
+		{Time: date(2018, 1, 15), Text: "Insurance 2", Amount: -3000},
+		{Time: date(2018, 3, 15), Text: "Insurance 3", Amount: -6000},
+		{Time: date(2018, 3, 15), Text: "Food 1", Amount: -400},
+		{Time: date(2018, 2, 10), Text: "Power 1", Amount: -2000},
+		{Time: date(2018, 3, 10), Text: "Power 2", Amount: -1500},
+	}
+	if _, err := j.Write("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	if !j.HasBudgetPeriods() {
+		t.Error("want budget periods")
+	}
+	march, err := j.Read(nil, date(2018, 3, 1), date(2018, 3, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Parents created by rollup get the amount spent in their children
+	gs := j.Rollup(j.Assort(march))
+	if err := j.Spend(gs, nil, date(2018, 3, 31)); err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range gs {
+		var want int64
+		switch g.Name {
+		case "Insurance":
+			want = -9000
+		case "Bills":
+			want = -3500
+			if c := g.Children[0]; c.Spent != -3500 {
+				t.Errorf("group %q: want spent %d, got %d", c.Name, -3500, c.Spent)
+			}
+		}
+		if g.Spent != want {
+			t.Errorf("group %q: want spent %d, got %d", g.Name, want, g.Spent)
+		}
+		if want, got := int64(-12000*31/365), g.Budget(record.Range{Since: date(2018, 3, 1), Until: date(2018, 3, 31)}); g.Name == "Insurance" && want != got {
+			t.Errorf("want budget %d, got %d", want, got)
+		}
+	}
+
+	var invalid = []struct {
+		group string
+		err   string
+	}{
+		{"budgetPeriod = \"fortnight\"", `group: "A": invalid budget period: "fortnight"`},
+		{"budgetPeriod = \"year\"\nbudgets = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12]", `group: "A": budgets cannot be combined with budget period "year"`},
+	}
+	for i, tt := range invalid {
+		conf, err := readConfig(strings.NewReader("Database = \":memory:\"\n[[groups]]\nname = \"A\"\n" + tt.group))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := conf.load(); err == nil || err.Error() != tt.err {
+			t.Errorf("#%d: want error %q, got %v", i, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package journal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+// A Problem describes a potential problem in the group configuration of a journal.
+type Problem struct {
+	Group   string
+	Rule    string
+	Message string
+}
+
+type ruleStats struct {
+	matched    int
+	won        int
+	shadowedBy []string // Pins and rules assorting records matched by the rule
+}
+
+func (st *ruleStats) shadow(by string) {
+	for _, s := range st.shadowedBy {
+		if s == by {
+			return
+		}
+	}
+	st.shadowedBy = append(st.shadowedBy, by)
+}
+
+func (g *Group) hasBudget() bool {
+	if nonZero(g.Budget, g.Budgets) {
+		return true
+	}
+	for _, p := range g.BudgetHistory {
+		if nonZero(p.Budget, p.Budgets) {
+			return true
+		}
+	}
+	return false
+}
+
+func nonZero(budget int64, budgets []int64) bool {
+	if budget != 0 {
+		return true
+	}
+	for _, b := range budgets {
+		if b != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint checks the group configuration against all records in the journal, and returns any problems found. Groups that
+// have a budget are expected to have records in the year preceding now.
+func (j *Journal) Lint(now time.Time) ([]Problem, error) {
+	rs, err := j.Read(nil, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	var (
+		stats   = make(map[*Rule]*ruleStats)
+		ids     = make(map[string]bool)
+		recent  = make(map[string]bool)
+		yearAgo = now.AddDate(-1, 0, 0)
+	)
+	for _, r := range rs {
+		ids[r.ID()] = true
+		winner := ""
+		j.visit(r, func(g *Group, rule *Rule, matched bool) bool {
+			if !matched {
+				return true
+			}
+			if winner == "" && !r.Time.Before(yearAgo) {
+				recent[g.Name] = true
+			}
+			if rule != nil {
+				st, ok := stats[rule]
+				if !ok {
+					st = &ruleStats{}
+					stats[rule] = st
+				}
+				st.matched++
+				if winner == "" {
+					st.won++
+				} else {
+					st.shadow(winner)
+				}
+			}
+			if winner == "" {
+				if rule != nil {
+					winner = fmt.Sprintf("%s in group %q", rule, g.Name)
+				} else {
+					winner = fmt.Sprintf("pin in group %q", g.Name)
+				}
+			}
+			return true
+		})
+	}
+
+	var problems []Problem
+	declared := make(map[string]int)
+	for i := range j.groups {
+		g := &j.groups[i]
+		if !g.stored {
+			declared[g.Name]++
+			if declared[g.Name] == 2 {
+				problems = append(problems, Problem{Group: g.Name, Message: "group is declared more than once"})
+			}
+		}
+		for _, id := range g.IDs {
+			if !ids[id] {
+				problems = append(problems, Problem{Group: g.Name, Rule: id, Message: "pinned record does not exist"})
+			}
+		}
+		for k := range g.rules {
+			rule := &g.rules[k]
+			st := stats[rule]
+			if st == nil {
+				problems = append(problems, Problem{Group: g.Name, Rule: rule.String(), Message: "rule matches no records"})
+			} else if st.won == 0 {
+				problems = append(problems, Problem{
+					Group:   g.Name,
+					Rule:    rule.String(),
+					Message: fmt.Sprintf("rule is shadowed: all %d matching record(s) are assorted by %s", st.matched, strings.Join(st.shadowedBy, ", ")),
+				})
+			}
+		}
+		if g.hasBudget() && !g.stored && !hasRecords(g.Name, recent) {
+			problems = append(problems, Problem{Group: g.Name, Message: "group has a budget, but no records in the last year"})
+		}
+	}
+	return problems, nil
+}
+
+// hasRecords returns whether group, or any group nested within it, is contained in groups.
+func hasRecords(group string, groups map[string]bool) bool {
+	for name := range groups {
+		if record.InGroup(name, group) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package journal
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestLint(t *testing.T) {
+	tomlConf := `
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "My account"
+
+[[groups]]
+name = "Groceries"
+budget = -1000
+patterns = ["^Rema", "^Kiwi"]
+
+[[groups]]
+name = "Rema"
+patterns = ["^Rema 1000"]
+
+[[groups]]
+name = "Travel"
+budget = -1000
+patterns = ["^Atb", "^Atb bus"]
+ids = ["deadbeef00"]
+
+[[groups]]
+name = "Groceries"
+patterns = ["^Bunnpris"]
+`
+	conf, err := readConfig(strings.NewReader(tomlConf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := []record.Record{
+		{Time: date(2018, 6, 1), Text: "Rema 1000", Amount: -100},
+		{Time: date(2017, 1, 1), Text: "Atb", Amount: -100},
+		{Time: date(2017, 1, 2), Text: "Atb bus", Amount: -100},
+	}
+	if _, err := j.Write("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	problems, err := j.Lint(date(2018, 7, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Problem{
+		{Group: "Groceries", Rule: "^Kiwi", Message: "rule matches no records"},
+		{Group: "Rema", Rule: "^Rema 1000", Message: `rule is shadowed: all 1 matching record(s) are assorted by ^Rema in group "Groceries"`},
+		{Group: "Travel", Rule: "deadbeef00", Message: "pinned record does not exist"},
+		{Group: "Travel", Rule: "^Atb bus", Message: `rule is shadowed: all 1 matching record(s) are assorted by ^Atb in group "Travel"`},
+		{Group: "Travel", Message: "group has a budget, but no records in the last year"},
+		{Group: "Groceries", Message: "group is declared more than once"},
+		{Group: "Groceries", Rule: "^Bunnpris", Message: "rule matches no records"},
+	}
+	if !reflect.DeepEqual(want, problems) {
+		t.Errorf("want %+v, got %+v", want, problems)
+	}
+}
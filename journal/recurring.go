@@ -0,0 +1,165 @@
+package journal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+// A cadence is the interval at which a recurring payment is charged.
+type cadence struct {
+	name             string
+	minDays, maxDays float64 // Accepted number of days between charges
+	years, months    int
+	days             int
+}
+
+var cadences = []cadence{
+	{name: "weekly", minDays: 6, maxDays: 8, days: 7},
+	{name: "monthly", minDays: 26, maxDays: 35, months: 1},
+	{name: "yearly", minDays: 350, maxDays: 380, years: 1},
+}
+
+func cadenceOf(name string) (cadence, bool) {
+	for _, c := range cadences {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return cadence{}, false
+}
+
+// occurrence returns the time of occurrence n of cadence c, counted from t.
+func (c *cadence) occurrence(t time.Time, n int) time.Time {
+	return t.AddDate(n*c.years, n*c.months, n*c.days)
+}
+
+// A PriceChange is a change in the amount charged by a recurring payment.
+type PriceChange struct {
+	Time time.Time // Time of the first charge with the new amount
+	From int64
+	To   int64
+}
+
+// A Recurring is a series of payments with similar texts, charged at a regular interval.
+type Recurring struct {
+	Name    string
+	Cadence string          // One of weekly, monthly or yearly
+	Amount  int64           // The most common amount charged
+	Records []record.Record // Charges, ordered by time, oldest first
+	Changes []PriceChange
+	cadence cadence
+}
+
+// Last returns the time of the most recent charge.
+func (r *Recurring) Last() time.Time { return r.Records[len(r.Records)-1].Time }
+
+// Next returns the time when the next charge is expected.
+func (r *Recurring) Next() time.Time { return r.cadence.occurrence(r.Last(), 1) }
+
+// findCadence returns the cadence matching at least three quarters of the intervals between charges in rs.
+func findCadence(rs []record.Record) (cadence, bool) {
+	for _, c := range cadences {
+		matches := 0
+		for i := 1; i < len(rs); i++ {
+			days := rs[i].Time.Sub(rs[i-1].Time).Hours() / 24
+			if days >= c.minDays && days <= c.maxDays {
+				matches++
+			}
+		}
+		if intervals := len(rs) - 1; matches*4 >= intervals*3 {
+			return c, true
+		}
+	}
+	return cadence{}, false
+}
+
+// priceChanges returns the changes in amount between consecutive charges in rs. It returns false if the amount
+// changes too often, or by too much, for rs to be a recurring payment.
+func priceChanges(rs []record.Record) ([]PriceChange, bool) {
+	var changes []PriceChange
+	for i := 1; i < len(rs); i++ {
+		from, to := rs[i-1].Amount, rs[i].Amount
+		if from == to {
+			continue
+		}
+		if abs(to-from)*2 > abs(from) {
+			return nil, false
+		}
+		changes = append(changes, PriceChange{Time: rs[i].Time, From: from, To: to})
+	}
+	if len(changes) > max(1, len(rs)/4) {
+		return nil, false
+	}
+	return changes, true
+}
+
+// typicalAmount returns the most common amount in rs. If several amounts are equally common, the most recent one is
+// returned.
+func typicalAmount(rs []record.Record) int64 {
+	counts := make(map[int64]int)
+	var amount int64
+	for _, r := range rs {
+		counts[r.Amount]++
+		if counts[r.Amount] >= counts[amount] {
+			amount = r.Amount
+		}
+	}
+	return amount
+}
+
+// Recurring finds recurring payments among records. Withdrawals are clustered by their normalised text, like in
+// Suggest, and each cluster of at least minCount records charged at a weekly, monthly or yearly cadence with a stable
+// amount is considered a recurring payment. Recurring payments are ordered by the time of their next expected charge.
+func (j *Journal) Recurring(records []record.Record, minCount int) []Recurring {
+	clusters := make(map[string][]record.Record)
+	clusterTokens := make(map[string][][]string)
+	for _, r := range records {
+		if r.Amount >= 0 {
+			continue
+		}
+		ts := tokens(r.Text)
+		if len(ts) == 0 {
+			continue
+		}
+		key := clusterKey(ts)
+		clusters[key] = append(clusters[key], r)
+		clusterTokens[key] = append(clusterTokens[key], ts)
+	}
+	var recurring []Recurring
+	for key, rs := range clusters {
+		if len(rs) < max(2, minCount) {
+			continue
+		}
+		sort.SliceStable(rs, func(i, k int) bool { return rs[i].Time.Before(rs[k].Time) })
+		c, ok := findCadence(rs)
+		if !ok {
+			continue
+		}
+		changes, ok := priceChanges(rs)
+		if !ok {
+			continue
+		}
+		r := Recurring{
+			Name:    title(commonTokens(clusterTokens[key])),
+			Cadence: c.name,
+			Amount:  typicalAmount(rs),
+			Records: rs,
+			Changes: changes,
+			cadence: c,
+		}
+		if r.Name == "" {
+			r.Name = rs[0].Text
+		}
+		recurring = append(recurring, r)
+	}
+	sort.Slice(recurring, func(i, k int) bool {
+		a, b := recurring[i], recurring[k]
+		if !a.Next().Equal(b.Next()) {
+			return a.Next().Before(b.Next())
+		}
+		return a.Name < b.Name
+	})
+	return recurring
+}
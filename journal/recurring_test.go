@@ -0,0 +1,59 @@
+package journal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestRecurring(t *testing.T) {
+	j := testJournal(t)
+	a := record.Account{Number: "1234.56.78900"}
+	var rs []record.Record
+	for i := 0; i < 6; i++ {
+		amount := int64(-9900)
+		if i >= 4 {
+			amount = -12900 // Price increase
+		}
+		rs = append(rs, record.Record{Account: a, Time: date(2018, time.Month(1+i), 15), Text: "Netflix.com 1234567890", Amount: amount})
+	}
+	for i := 0; i < 4; i++ {
+		rs = append(rs, record.Record{Account: a, Time: date(2018, 1, 1+i*7), Text: "Gym weekly", Amount: -5000})
+		rs = append(rs, record.Record{Account: a, Time: date(2018, 1, 2+i*7), Text: "Groceries", Amount: -1000 * int64(i+1)})
+		rs = append(rs, record.Record{Account: a, Time: date(2018, 1, 3+i*9), Text: "Irregular", Amount: -1000})
+	}
+	rs = append(rs,
+		record.Record{Account: a, Time: date(2016, 3, 1), Text: "Domain renewal", Amount: -10000},
+		record.Record{Account: a, Time: date(2017, 3, 1), Text: "Domain renewal", Amount: -10000},
+		record.Record{Account: a, Time: date(2018, 3, 2), Text: "Domain renewal", Amount: -10000},
+		record.Record{Account: a, Time: date(2018, 1, 25), Text: "Salary", Amount: 100000},
+		record.Record{Account: a, Time: date(2018, 2, 25), Text: "Salary", Amount: 100000},
+		record.Record{Account: a, Time: date(2018, 3, 25), Text: "Salary", Amount: 100000},
+	)
+	var tests = []struct {
+		name    string
+		cadence string
+		amount  int64
+		next    time.Time
+		changes int
+	}{
+		{"Gym Weekly", "weekly", -5000, date(2018, 1, 29), 0},
+		{"Netflix.com", "monthly", -9900, date(2018, 7, 15), 1},
+		{"Domain Renewal", "yearly", -10000, date(2019, 3, 2), 0},
+	}
+	recurring := j.Recurring(rs, 3)
+	if len(recurring) != len(tests) {
+		t.Fatalf("want %d recurring payments, got %d: %+v", len(tests), len(recurring), recurring)
+	}
+	for i, tt := range tests {
+		r := recurring[i]
+		if r.Name != tt.name || r.Cadence != tt.cadence || r.Amount != tt.amount || !r.Next().Equal(tt.next) || len(r.Changes) != tt.changes {
+			t.Errorf("#%d: want %s: cadence = %s, amount = %d, next = %s, changes = %d; got %s: cadence = %s, amount = %d, next = %s, changes = %d",
+				i, tt.name, tt.cadence, tt.amount, tt.next, tt.changes, r.Name, r.Cadence, r.Amount, r.Next(), len(r.Changes))
+		}
+	}
+	if c := recurring[1].Changes[0]; !c.Time.Equal(date(2018, 5, 15)) || c.From != -9900 || c.To != -12900 {
+		t.Errorf("unexpected price change: %+v", c)
+	}
+}
@@ -0,0 +1,213 @@
+package journal
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mpolden/journal/record"
+)
+
+var (
+	datePattern = regexp.MustCompile(`^\d{1,4}[./-]\d{1,2}([./-]\d{1,4})?$`)
+	timePattern = regexp.MustCompile(`^\d{1,2}:\d{2}(:\d{2})?$`)
+	cardPattern = regexp.MustCompile(`^[*x]+\d*$|^\d*[*x]{2,}\d*$`)
+)
+
+// A Suggestion is a proposed group rule for a cluster of similar records in the default group.
+type Suggestion struct {
+	Name    string
+	Pattern string
+	Group   string
+	Records []record.Record
+}
+
+// Sum returns the total amount of records in the suggestion.
+func (s *Suggestion) Sum() int64 {
+	var sum int64
+	for _, r := range s.Records {
+		sum += r.Amount
+	}
+	return sum
+}
+
+// tokens splits text into lower-case words, leaving out words that look like dates, times, card numbers and
+// reference numbers.
+func tokens(text string) []string {
+	var ts []string
+	for _, field := range strings.Fields(strings.ToLower(text)) {
+		field = strings.TrimFunc(field, func(r rune) bool { return unicode.IsPunct(r) && r != '*' })
+		if field == "" || datePattern.MatchString(field) || timePattern.MatchString(field) ||
+			cardPattern.MatchString(field) {
+			continue
+		}
+		digits := 0
+		for _, r := range field {
+			if unicode.IsDigit(r) {
+				digits++
+			}
+		}
+		if digits >= 6 {
+			continue
+		}
+		ts = append(ts, field)
+	}
+	return ts
+}
+
+func isNumber(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// clusterKey returns the key identifying the cluster of ts. Numbers are replaced by a placeholder, so that texts
+// differing only in numbers, such as card numbers, belong to the same cluster.
+func clusterKey(ts []string) string {
+	key := make([]string, len(ts))
+	for i, t := range ts {
+		if isNumber(t) {
+			t = "#"
+		}
+		key[i] = t
+	}
+	return strings.Join(key, " ")
+}
+
+// commonTokens returns the tokens shared by all token lists in tss, which must have equal length. Tokens that differ
+// are returned as an empty string.
+func commonTokens(tss [][]string) []string {
+	common := append([]string(nil), tss[0]...)
+	for _, ts := range tss[1:] {
+		for i, t := range ts {
+			if common[i] != t {
+				common[i] = ""
+			}
+		}
+	}
+	return common
+}
+
+// suggestPattern returns the most specific pattern built from common tokens ts that matches all records in rs.
+func suggestPattern(ts []string, rs []record.Record) string {
+	quoted := make([]string, len(ts))
+	for i, t := range ts {
+		if t == "" {
+			quoted[i] = `\d+`
+		} else {
+			quoted[i] = regexp.QuoteMeta(t)
+		}
+	}
+	candidates := []string{
+		"(?i)^" + strings.Join(quoted, " "),
+		"(?i)" + strings.Join(quoted, " "),
+	}
+	for _, c := range candidates {
+		p := regexp.MustCompile(c)
+		matchesAll := true
+		for _, r := range rs {
+			if !p.MatchString(r.Text) {
+				matchesAll = false
+				break
+			}
+		}
+		if matchesAll {
+			return c
+		}
+	}
+	return "(?i)" + strings.Join(quoted, ".*")
+}
+
+// SuggestPattern returns a pattern matching the text of record r, ignoring dates, times and reference numbers.
+func SuggestPattern(r record.Record) string {
+	ts := tokens(r.Text)
+	if len(ts) == 0 {
+		return "^" + regexp.QuoteMeta(r.Text) + "$"
+	}
+	return suggestPattern(ts, []record.Record{r})
+}
+
+func title(ts []string) string {
+	var words []string
+	for _, t := range ts {
+		if t == "" {
+			continue
+		}
+		rs := []rune(t)
+		rs[0] = unicode.ToUpper(rs[0])
+		words = append(words, string(rs))
+	}
+	return strings.Join(words, " ")
+}
+
+// Suggest clusters records that would be assorted into the default group by their normalised text, and proposes a
+// pattern for each cluster containing at least minCount records. Suggestions are ordered by number of records, and then
+// by absolute sum. Each suggestion names the existing group, if any, whose records most often share the first word of
+// the cluster.
+func (j *Journal) Suggest(records []record.Record, minCount int) []Suggestion {
+	clusters := make(map[string][]record.Record)
+	clusterTokens := make(map[string][][]string)
+	firstWords := make(map[string]map[string]int)
+	for _, r := range records {
+		ts := tokens(r.Text)
+		if len(ts) == 0 {
+			continue
+		}
+		g := j.findGroup(r)
+		if g == nil {
+			continue
+		}
+		if g.Name != j.DefaultGroup {
+			if firstWords[ts[0]] == nil {
+				firstWords[ts[0]] = make(map[string]int)
+			}
+			firstWords[ts[0]][g.Name]++
+			continue
+		}
+		key := clusterKey(ts)
+		clusters[key] = append(clusters[key], r)
+		clusterTokens[key] = append(clusterTokens[key], ts)
+	}
+	var suggestions []Suggestion
+	for key, rs := range clusters {
+		if len(rs) < minCount {
+			continue
+		}
+		ts := commonTokens(clusterTokens[key])
+		s := Suggestion{Name: title(ts), Pattern: suggestPattern(ts, rs), Records: rs}
+		if s.Name == "" {
+			s.Name = rs[0].Text
+		}
+		best := 0
+		for group, n := range firstWords[clusterTokens[key][0][0]] {
+			if n > best || (n == best && group < s.Group) {
+				s.Group = group
+				best = n
+			}
+		}
+		suggestions = append(suggestions, s)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if len(a.Records) != len(b.Records) {
+			return len(a.Records) > len(b.Records)
+		}
+		sa, sb := abs(a.Sum()), abs(b.Sum())
+		if sa != sb {
+			return sa > sb
+		}
+		return a.Name < b.Name
+	})
+	return suggestions
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,76 @@
+package journal
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestTokens(t *testing.T) {
+	var tests = []struct {
+		in  string
+		out []string
+	}{
+		{"Rema 1000", []string{"rema", "1000"}},
+		{"VISA *1234 REMA 1000 TRONDHEIM 12.03", []string{"visa", "rema", "1000", "trondheim"}},
+		{"Netflix.com 2018-01-02 12:34 Ref 12345678", []string{"netflix.com", "ref"}},
+		{"Kiwi, Oslo XXXX1234", []string{"kiwi", "oslo"}},
+	}
+	for i, tt := range tests {
+		if got := tokens(tt.in); !reflect.DeepEqual(tt.out, got) {
+			t.Errorf("#%d: want %q, got %q", i, tt.out, got)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	j := testJournal(t)
+	a := record.Account{Number: "1234.56.78900"}
+	rs := []record.Record{
+		{Account: a, Time: date(2018, 1, 1), Text: "Foo store", Amount: -100},                 // Travel
+		{Account: a, Time: date(2018, 1, 2), Text: "FOO shop 01.02", Amount: -200},            // Ungrouped
+		{Account: a, Time: date(2018, 1, 3), Text: "FOO shop 02.02", Amount: -300},            // Ungrouped
+		{Account: a, Time: date(2018, 1, 4), Text: "Netflix 123456789", Amount: -1000},        // Ungrouped
+		{Account: a, Time: date(2018, 2, 4), Text: "Netflix 987654321", Amount: -1000},        // Ungrouped
+		{Account: a, Time: date(2018, 1, 5), Text: "Oneoff", Amount: -5000},                   // Ungrouped, single record
+		{Account: a, Time: date(2018, 1, 6), Text: "Spam", Amount: -5000},                     // Discarded
+		{Account: a, Time: date(2018, 1, 7), Text: "VISA 1111 Cafe, Oslo", Amount: -10},       // Ungrouped
+		{Account: a, Time: date(2018, 1, 8), Text: "VISA 2222 Cafe,   Oslo.", Amount: -10},    // Ungrouped
+		{Account: a, Time: date(2018, 1, 9), Text: "Kiosk Oslo 111111111111", Amount: -10},    // Ungrouped
+		{Account: a, Time: date(2018, 1, 9), Text: "Ref 222222222222 Kiosk Oslo", Amount: -5}, // Ungrouped, different cluster
+	}
+	suggestions := j.Suggest(rs, 2)
+	var tests = []struct {
+		name    string
+		pattern string
+		group   string
+		records int
+		sum     int64
+	}{
+		{"Netflix", "(?i)^netflix", "", 2, -2000},
+		{"Foo Shop", "(?i)^foo shop", "Travel", 2, -500},
+		{"Visa Cafe Oslo", `(?i)visa.*\d+.*cafe.*oslo`, "", 2, -20},
+	}
+	if want, got := len(tests), len(suggestions); want != got {
+		t.Fatalf("want %d suggestions, got %d: %+v", want, got, suggestions)
+	}
+	for i, tt := range tests {
+		s := suggestions[i]
+		if s.Name != tt.name {
+			t.Errorf("#%d: want Name = %q, got %q", i, tt.name, s.Name)
+		}
+		if s.Pattern != tt.pattern {
+			t.Errorf("#%d: want Pattern = %q, got %q", i, tt.pattern, s.Pattern)
+		}
+		if s.Group != tt.group {
+			t.Errorf("#%d: want Group = %q, got %q", i, tt.group, s.Group)
+		}
+		if len(s.Records) != tt.records {
+			t.Errorf("#%d: want %d records, got %d", i, tt.records, len(s.Records))
+		}
+		if s.Sum() != tt.sum {
+			t.Errorf("#%d: want Sum = %d, got %d", i, tt.sum, s.Sum())
+		}
+	}
+}
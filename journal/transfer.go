@@ -0,0 +1,137 @@
+package journal
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mpolden/journal/record"
+	"github.com/mpolden/journal/sql"
+)
+
+const defaultTransferDays = 3
+
+// A Transfer is a pair of records moving money from one account to another.
+type Transfer struct {
+	From record.Record // The record withdrawing money
+	To   record.Record // The record depositing money
+}
+
+func timeDistance(a, b time.Time) time.Duration {
+	if d := a.Sub(b); d > 0 {
+		return d
+	}
+	return b.Sub(a)
+}
+
+// DetectTransfers finds transfers between accounts declared in the configuration, and marks them as transfers in the
+// journal. A withdrawal and a deposit of the same amount in different accounts is a transfer if they occur within
+// transferDays of each other. If several deposits match a withdrawal, the one closest in time is chosen. The number
+// of new transfers is returned.
+func (j *Journal) DetectTransfers() (int64, error) {
+	return j.detectTransfers(0, time.Time{}, time.Time{})
+}
+
+// detectTransfers finds transfers involving at least one record added after the record with row ID last. Only records
+// within transferDays of the times since and until are considered.
+func (j *Journal) detectTransfers(last int64, since, until time.Time) (int64, error) {
+	if len(j.accounts) < 2 {
+		return 0, nil
+	}
+	numbers := make([]string, len(j.accounts))
+	for i, a := range j.accounts {
+		numbers[i] = a.Number
+	}
+	window := time.Duration(j.transferDays) * 24 * time.Hour
+	if !since.IsZero() {
+		since = since.Add(-window)
+	}
+	if !until.IsZero() {
+		until = until.Add(window)
+	}
+	rs, err := j.db.SelectRecordsBetween(numbers, since, until)
+	if err != nil {
+		return 0, err
+	}
+	sort.SliceStable(rs, func(i, k int) bool {
+		if rs[i].Time == rs[k].Time {
+			return rs[i].ID < rs[k].ID
+		}
+		return rs[i].Time < rs[k].Time
+	})
+	var withdrawals []sql.Record
+	deposits := make(map[int64][]sql.Record)
+	for _, r := range rs {
+		if r.Transfer {
+			continue
+		}
+		switch {
+		case r.Amount < 0:
+			withdrawals = append(withdrawals, r)
+		case r.Amount > 0:
+			deposits[r.Amount] = append(deposits[r.Amount], r)
+		}
+	}
+	distance := func(a, b sql.Record) time.Duration {
+		return timeDistance(time.Unix(a.Time, 0), time.Unix(b.Time, 0))
+	}
+	used := make(map[int64]bool)
+	var ts []sql.Transfer
+	for _, w := range withdrawals {
+		best := -1
+		candidates := deposits[-w.Amount]
+		for i, d := range candidates {
+			if d.Number == w.Number || used[d.ID] || distance(w, d) > window {
+				continue
+			}
+			if w.ID <= last && d.ID <= last {
+				continue // Both records have been considered before
+			}
+			if best == -1 || distance(w, d) < distance(w, candidates[best]) {
+				best = i
+			}
+		}
+		if best == -1 {
+			continue
+		}
+		to := candidates[best].ID
+		used[to] = true
+		ts = append(ts, sql.Transfer{From: w.ID, To: to})
+	}
+	if len(ts) == 0 {
+		return 0, nil
+	}
+	return j.db.AddTransfers(ts)
+}
+
+// Transfers returns the transfers withdrawing money between the times since and until, ordered by time, newest first.
+func (j *Journal) Transfers(since, until time.Time) ([]Transfer, error) {
+	ts, err := j.db.SelectTransfers()
+	if err != nil {
+		return nil, err
+	}
+	if len(ts) == 0 {
+		return nil, nil
+	}
+	rs, err := j.db.SelectRecordsBetween(nil, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	records := make(map[int64]record.Record, len(rs))
+	for _, r := range rs {
+		records[r.ID] = newRecord(r)
+	}
+	var transfers []Transfer
+	for _, t := range ts {
+		from, ok1 := records[t.From]
+		to, ok2 := records[t.To]
+		if !ok1 || !ok2 {
+			continue
+		}
+		if (!since.IsZero() && from.Time.Before(since)) || (!until.IsZero() && from.Time.After(until)) {
+			continue
+		}
+		transfers = append(transfers, Transfer{From: from, To: to})
+	}
+	sort.SliceStable(transfers, func(i, k int) bool { return transfers[i].From.Time.After(transfers[k].From.Time) })
+	return transfers, nil
+}
@@ -0,0 +1,136 @@
+package journal
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mpolden/journal/record"
+)
+
+func TestTransfers(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(`
+Database = ":memory:"
+
+[[accounts]]
+number = "1.2.3"
+name = "Checking"
+
+[[accounts]]
+number = "4.5.6"
+name = "Savings"
+
+[[groups]]
+name = "Groceries"
+patterns = ["^Groceries"]
+
+[[groups]]
+name = "Misc"
+budget = -5000
+patterns = ["^To savings"]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := j.Write("1.2.3", []record.Record{
+		{Time: date(2018, 1, 5), Text: "To savings", Amount: -10000},
+		{Time: date(2018, 1, 20), Text: "Groceries", Amount: -5000},
+		{Time: date(2018, 2, 1), Text: "From savings", Amount: 2000},
+		{Time: date(2018, 3, 1), Text: "To savings", Amount: -30000},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	writes, err := j.Write("4.5.6", []record.Record{
+		{Time: date(2018, 1, 4), Text: "Deposit", Amount: 10000}, // Closest in time
+		{Time: date(2018, 1, 7), Text: "Deposit", Amount: 10000},
+		{Time: date(2018, 1, 30), Text: "Refund", Amount: 5000}, // Too late
+		{Time: date(2018, 1, 31), Text: "Withdrawal", Amount: -2000},
+		{Time: date(2018, 3, 8), Text: "Deposit", Amount: 30000}, // Too late
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(2); writes.Transfer != want {
+		t.Errorf("want %d transfers, got %d", want, writes.Transfer)
+	}
+
+	transfers, err := j.Transfers(date(2018, 1, 1), date(2018, 2, 28))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		from, to string
+		amount   int64
+	}{
+		{"Withdrawal", "From savings", 2000},
+		{"To savings", "Deposit", 10000},
+	}
+	if len(transfers) != len(tests) {
+		t.Fatalf("want %d transfers, got %d", len(tests), len(transfers))
+	}
+	if want := date(2018, 1, 4); !transfers[1].To.Time.Equal(want) {
+		t.Errorf("want deposit at %s, got %s", want, transfers[1].To.Time)
+	}
+	for i, tt := range tests {
+		tr := transfers[i]
+		if tr.From.Text != tt.from || tr.To.Text != tt.to || tr.To.Amount != tt.amount {
+			t.Errorf("#%d: want %s -> %s (%d), got %s -> %s (%d)", i, tt.from, tt.to, tt.amount, tr.From.Text, tr.To.Text, tr.To.Amount)
+		}
+	}
+
+	// Records part of a transfer are flagged, but still assorted
+	rs, err := j.Read(nil, date(2018, 1, 1), date(2018, 1, 31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, r := range rs {
+		if r.Transfer {
+			n++
+		}
+	}
+	if want := 3; n != want {
+		t.Errorf("want %d records in transfers, got %d", want, n)
+	}
+	assorted := 0
+	for _, rg := range j.Assort(rs) {
+		assorted += len(rg.Records)
+	}
+	if want := len(rs); assorted != want {
+		t.Errorf("want %d assorted records, got %d", want, assorted)
+	}
+
+	// Transfers are not counted in budgets, unless the filter selects them
+	alerts, err := j.Alerts(date(2018, 1, 20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 0 {
+		t.Errorf("want no alerts, got %+v", alerts)
+	}
+	j.Filter.Transfers = true
+	if alerts, err = j.Alerts(date(2018, 1, 20)); err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 1 || !alerts[0].Over() {
+		t.Errorf("want alert for transfer over budget, got %+v", alerts)
+	}
+	j.Filter.Transfers = false
+
+	// Newly imported records are matched against existing ones
+	writes, err = j.Write("1.2.3", []record.Record{{Time: date(2018, 1, 8), Text: "To savings", Amount: -10000}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(1); writes.Transfer != want {
+		t.Errorf("want %d transfers, got %d", want, writes.Transfer)
+	}
+
+	// Detection is idempotent
+	if n, err := j.DetectTransfers(); err != nil || n != 0 {
+		t.Errorf("want 0 new transfers, got %d (err = %v)", n, err)
+	}
+}
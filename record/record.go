@@ -34,44 +34,106 @@ const (
 	SumField
 )
 
+// Separator separates the levels in the name of a hierarchical group.
+const Separator = "/"
+
+const (
+	// CheckingAccount is the type of a checking account.
+	CheckingAccount = "checking"
+
+	// SavingsAccount is the type of a savings account.
+	SavingsAccount = "savings"
+
+	// CreditCardAccount is the type of a credit card account.
+	CreditCardAccount = "creditcard"
+
+	// LoanAccount is the type of a loan account.
+	LoanAccount = "loan"
+)
+
+const (
+	// MonthPeriod is a budget period of one calendar month.
+	MonthPeriod = "month"
+
+	// QuarterPeriod is a budget period of one calendar quarter.
+	QuarterPeriod = "quarter"
+
+	// YearPeriod is a budget period of one calendar year.
+	YearPeriod = "year"
+
+	// WeekPeriod is a budget period of one week, starting on Monday.
+	WeekPeriod = "week"
+)
+
 // Reader is the interface for record readers.
 type Reader interface {
 	Read() ([]Record, error)
 }
 
-// A Budget represents a budget for a group of records.
+// A Budget represents a budget for a group of records. Periods holds any later budgets, in chronological order. Each
+// of them replaces the budget preceding it, starting from the month containing its Since time. Period is the period
+// which the budget applies to, and defaults to MonthPeriod. Months is only used with MonthPeriod.
 type Budget struct {
 	Default int64
 	Months  [12]int64
+	Periods []BudgetPeriod
+	Period  string
+}
+
+// A BudgetPeriod is a budget taking effect at a given time.
+type BudgetPeriod struct {
+	Since   time.Time
+	Default int64
+	Months  [12]int64
 }
 
 // An Account identifies a finanical account.
 type Account struct {
-	Number  string
-	Name    string
-	Records int64
+	Number         string
+	Name           string
+	Type           string
+	Institution    string
+	OpeningBalance int64
+	OpeningTime    time.Time
+	Closed         bool
+	Records        int64
+	Balance        int64
 }
 
 // A Record is a record of a finanical transaction.
 type Record struct {
-	Account Account
-	Time    time.Time
-	Text    string
-	Amount  int64
-	Balance int64
+	Account  Account
+	Time     time.Time
+	Text     string
+	Amount   int64
+	Balance  int64
+	Transfer bool   // Set if this record is part of a transfer between accounts
+	Slice    *Slice // Set if this record is a slice of an amortized record
+}
+
+// A Slice is a part of a record that is amortized over several months.
+type Slice struct {
+	Original Record
+	N        int // Number of this slice, starting at 1
+	Of       int // Total number of slices
 }
 
-// A Group is a list of records grouped together under a common name.
+// A Group is a list of records grouped together under a common name. The name of a nested group contains the names
+// of its ancestors, separated by Separator.
 type Group struct {
-	Name    string
-	Records []Record
-	budget  Budget
+	Name     string
+	Records  []Record
+	Children []Group
+	Carry    int64 // Balance carried over from earlier periods
+	Spent    int64 // Sum of records in the current budget period
+	budget   Budget
 }
 
 // A Range represents a record time range.
 type Range struct {
-	Since time.Time
-	Until time.Time
+	Since   time.Time
+	Until   time.Time
+	Prorate bool // Whether monthly budgets are prorated by the number of days in the range
 }
 
 // A Period is a list of record groups occurring at a specific time.
@@ -96,19 +158,85 @@ func NewReader(rd io.Reader) Reader {
 	}
 }
 
-// Month returns the budget for month.
-func (b *Budget) Month(m time.Month) int64 {
-	monthly := false
-	for _, n := range b.Months {
-		if n != 0 {
-			monthly = true
+// Month returns the budget in effect in the month containing t. For a budget of another period than MonthPeriod, this
+// is the budget of the whole period.
+func (b *Budget) Month(t time.Time) int64 {
+	budget, months := b.Default, &b.Months
+	for i := range b.Periods {
+		p := &b.Periods[i]
+		if t.Year() < p.Since.Year() || (t.Year() == p.Since.Year() && t.Month() < p.Since.Month()) {
 			break
 		}
+		budget, months = p.Default, &p.Months
+	}
+	return monthBudget(budget, months, t.Month())
+}
+
+func monthBudget(budget int64, months *[12]int64, m time.Month) int64 {
+	if zeroMonths(months) {
+		return budget
+	}
+	return months[m-1]
+}
+
+func zeroMonths(months *[12]int64) bool {
+	for _, n := range months {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Budget) zero() bool {
+	if b.Default != 0 || !zeroMonths(&b.Months) {
+		return false
+	}
+	for i := range b.Periods {
+		if b.Periods[i].Default != 0 || !zeroMonths(&b.Periods[i].Months) {
+			return false
+		}
+	}
+	return true
+}
+
+// Liability returns whether this account represents money owed, such as a credit card or a loan.
+func (a *Account) Liability() bool { return a.Type == CreditCardAccount || a.Type == LoanAccount }
+
+// Original returns the record that this record is a slice of, or the record itself if it is not a slice.
+func (r *Record) Original() Record {
+	if r.Slice != nil {
+		return r.Slice.Original
+	}
+	return *r
+}
+
+// Amortize splits this record into slices of equal amount, occurring on the same day in the given number of
+// consecutive months. Any remainder is added to the first slice. If months is less than 2, the record is returned
+// unchanged.
+func (r *Record) Amortize(months int) []Record {
+	if months < 2 {
+		return []Record{*r}
 	}
-	if monthly {
-		return b.Months[m-1]
+	rs := make([]Record, months)
+	amount := r.Amount / int64(months)
+	for i := range rs {
+		t := time.Date(r.Time.Year(), r.Time.Month()+time.Month(i), 1, r.Time.Hour(), r.Time.Minute(), r.Time.Second(), 0, r.Time.Location())
+		if lastDay := t.AddDate(0, 1, -1).Day(); r.Time.Day() < lastDay {
+			t = t.AddDate(0, 0, r.Time.Day()-1)
+		} else {
+			t = t.AddDate(0, 0, lastDay-1)
+		}
+		rs[i] = Record{
+			Account: r.Account,
+			Time:    t,
+			Text:    r.Text,
+			Amount:  amount,
+			Slice:   &Slice{Original: *r, N: i + 1, Of: months},
+		}
 	}
-	return b.Default
+	rs[0].Amount += r.Amount - amount*int64(months)
+	return rs
 }
 
 // ID returns a shortened SHA-1 hash of the fields in this record.
@@ -126,11 +254,40 @@ func (r *Record) ID() string {
 	return fmt.Sprintf("%x", sum)[:10]
 }
 
-func (r *Range) months() []time.Month {
-	var months []time.Month
+// PeriodRange returns the budget period containing t.
+func PeriodRange(period string, t time.Time) Range {
+	var since time.Time
+	switch period {
+	case YearPeriod:
+		since = time.Date(t.Year(), 1, 1, 0, 0, 0, 0, t.Location())
+		return Range{Since: since, Until: since.AddDate(1, 0, -1)}
+	case QuarterPeriod:
+		since = time.Date(t.Year(), t.Month()-(t.Month()-1)%3, 1, 0, 0, 0, 0, t.Location())
+		return Range{Since: since, Until: since.AddDate(0, 3, -1)}
+	case WeekPeriod:
+		weekday := (int(t.Weekday()) + 6) % 7 // Days since Monday
+		since = time.Date(t.Year(), t.Month(), t.Day()-weekday, 0, 0, 0, 0, t.Location())
+		return Range{Since: since, Until: since.AddDate(0, 0, 6)}
+	}
+	since = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	return Range{Since: since, Until: since.AddDate(0, 1, -1)}
+}
+
+// days returns the number of days in this range, counting both the first and last day.
+func (r *Range) days() int64 {
+	since := time.Date(r.Since.Year(), r.Since.Month(), r.Since.Day(), 0, 0, 0, 0, time.UTC)
+	until := time.Date(r.Until.Year(), r.Until.Month(), r.Until.Day(), 0, 0, 0, 0, time.UTC)
+	if until.Before(since) {
+		return 0
+	}
+	return int64(until.Sub(since).Hours()/24) + 1
+}
+
+func (r *Range) months() []time.Time {
+	var months []time.Time
 	t := r.Since
 	for !t.After(r.Until) {
-		months = append(months, t.Month())
+		months = append(months, t)
 		t = t.AddDate(0, 1, 0)
 	}
 	return months
@@ -145,18 +302,103 @@ func (g *Group) Sum() int64 {
 	return sum
 }
 
-// Budget returns the budget for this group. The budget is adjusted to the number of months in range r.
+// Budget returns the budget for this group. A monthly budget is adjusted to the number of months in range r, unless
+// r is prorated. Budgets of other periods, and prorated monthly budgets, are prorated by the number of days of each
+// period in range r. A group without a budget of its own has the combined budget of its children.
 func (g *Group) Budget(r Range) int64 {
 	var budget int64
+	if g.budget.zero() {
+		for _, c := range g.Children {
+			budget += c.Budget(r)
+		}
+		return budget
+	}
+	if period := g.BudgetPeriod(); period != MonthPeriod || r.Prorate {
+		for p := PeriodRange(period, r.Since); !p.Since.After(r.Until); p = PeriodRange(period, p.Until.AddDate(0, 0, 1)) {
+			overlap := Range{Since: p.Since, Until: p.Until}
+			if r.Since.After(overlap.Since) {
+				overlap.Since = r.Since
+			}
+			if r.Until.Before(overlap.Until) {
+				overlap.Until = r.Until
+			}
+			budget += g.budget.Month(p.Since) * overlap.days() / p.days()
+		}
+		return budget
+	}
 	for _, m := range r.months() {
 		budget += g.budget.Month(m)
 	}
 	return budget
 }
 
-// Balance returns the difference between the budget of this group and its sum. Balance adjusts the budget using
-// range r in the same way that Budget does.
-func (g *Group) Balance(r Range) int64 { return g.Budget(r) - g.Sum() }
+// Pace returns the projected sum of this group at the end of the month containing the end of range r, assuming that
+// records continue to occur at the same daily rate as in range r.
+func (g *Group) Pace(r Range) int64 {
+	elapsed := r.days()
+	if elapsed == 0 {
+		return g.Sum()
+	}
+	month := Range{Since: r.Since, Until: PeriodRange(MonthPeriod, r.Until).Until}
+	return g.Sum() * month.days() / elapsed
+}
+
+// OverPace returns whether the projected sum of this group, as returned by Pace, exceeds its budget at the end of the
+// month containing the end of range r.
+func (g *Group) OverPace(r Range) bool {
+	month := Range{Since: r.Since, Until: PeriodRange(MonthPeriod, r.Until).Until}
+	budget := g.Budget(month)
+	return budget < 0 && g.Pace(r) < budget
+}
+
+// BudgetPeriod returns the period which the budget of this group applies to.
+func (g *Group) BudgetPeriod() string {
+	if g.budget.Period == "" {
+		return MonthPeriod
+	}
+	return g.budget.Period
+}
+
+// HasBudget returns whether this group has a budget of its own.
+func (g *Group) HasBudget() bool { return !g.budget.zero() }
+
+// Utilisation returns the fraction of the budget used in the budget period containing t, where the amount used is
+// given by Spent. Utilisation returns false if this group has no budget of its own for that period.
+func (g *Group) Utilisation(t time.Time) (float64, bool) {
+	budget := g.Budget(PeriodRange(g.BudgetPeriod(), t))
+	if !g.HasBudget() || budget == 0 {
+		return 0, false
+	}
+	return float64(g.Spent) / float64(budget), true
+}
+
+// Depth returns the depth of this group in a group hierarchy. Top-level groups have depth 1.
+func (g *Group) Depth() int { return strings.Count(g.Name, Separator) + 1 }
+
+// BaseName returns the last element of this group's name.
+func (g *Group) BaseName() string { return g.Name[strings.LastIndex(g.Name, Separator)+1:] }
+
+// InGroup returns whether the group named name is group, or a group nested under it.
+func InGroup(name, group string) bool {
+	return name == group || strings.HasPrefix(name, group+Separator)
+}
+
+// Carried returns the balance carried over into this group from earlier periods. A group without a budget of its own
+// has the combined carried balance of its children.
+func (g *Group) Carried() int64 {
+	if !g.budget.zero() {
+		return g.Carry
+	}
+	carried := g.Carry
+	for _, c := range g.Children {
+		carried += c.Carried()
+	}
+	return carried
+}
+
+// Balance returns the difference between the budget of this group, including any carried balance, and its sum.
+// Balance adjusts the budget using range r in the same way that Budget does.
+func (g *Group) Balance(r Range) int64 { return g.Budget(r) + g.Carried() - g.Sum() }
 
 // MaxBalance returns the highest balance of the groups in gs. MaxBalance adjusts the budget using range r in the
 // same way that Budget does.
@@ -182,6 +424,76 @@ func MinBalance(gs []Group, r Range) int64 {
 	return min
 }
 
+// Rollup arranges groups gs into a hierarchy based on their names, and returns the top-level groups. Each group
+// contains its nested groups as children, and records of its children in addition to its own. Ancestors missing from
+// gs are created by newGroup.
+func Rollup(gs []Group, newGroup func(name string) Group) []Group { return rollup(gs, "", newGroup) }
+
+func rollup(gs []Group, prefix string, newGroup func(string) Group) []Group {
+	own := make(map[string]Group)
+	descendants := make(map[string][]Group)
+	var names []string
+	for _, g := range gs {
+		head, _, nested := strings.Cut(strings.TrimPrefix(g.Name, prefix), Separator)
+		name := prefix + head
+		if _, ok := own[name]; !ok && descendants[name] == nil {
+			names = append(names, name)
+		}
+		if nested {
+			descendants[name] = append(descendants[name], g)
+		} else {
+			own[name] = g
+		}
+	}
+	sort.Strings(names)
+	groups := make([]Group, 0, len(names))
+	for _, name := range names {
+		g, ok := own[name]
+		if !ok {
+			g = newGroup(name)
+		}
+		g.Records = append([]Record(nil), g.Records...)
+		g.Children = rollup(descendants[name], name+Separator, newGroup)
+		for _, c := range g.Children {
+			g.Records = append(g.Records, c.Records...)
+		}
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// Collapse merges groups in gs that are nested deeper than depth into their ancestor at depth. Ancestors are created
+// by newGroup. If depth is zero, gs is returned unchanged.
+func Collapse(gs []Group, depth int, newGroup func(name string) Group) []Group {
+	if depth <= 0 {
+		return gs
+	}
+	m := make(map[string]Group)
+	for _, g := range gs {
+		name := g.Name
+		if g.Depth() > depth {
+			name = strings.Join(strings.SplitN(g.Name, Separator, depth+1)[:depth], Separator)
+		}
+		target, ok := m[name]
+		if !ok {
+			if name == g.Name {
+				target = g
+				target.Records = nil
+			} else {
+				target = newGroup(name)
+			}
+		}
+		target.Records = append(target.Records, g.Records...)
+		m[name] = target
+	}
+	collapsed := make([]Group, 0, len(m))
+	for _, g := range m {
+		collapsed = append(collapsed, g)
+	}
+	sort.Slice(collapsed, func(i, j int) bool { return collapsed[i].Name < collapsed[j].Name })
+	return collapsed
+}
+
 // AssortFunc uses groupFn to assort records into groups.
 func AssortFunc(records []Record, assortFn func(Record) *Group) []Group {
 	m := make(map[string]Group)
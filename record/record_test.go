@@ -102,6 +102,37 @@ func TestID(t *testing.T) {
 	}
 }
 
+func TestAmortize(t *testing.T) {
+	r := Record{Time: date(2017, 11, 30), Text: "Laptop", Amount: -1000}
+	rs := r.Amortize(3)
+	var want = []struct {
+		t      time.Time
+		amount int64
+	}{
+		{date(2017, 11, 30), -334},
+		{date(2017, 12, 30), -333},
+		{date(2018, 1, 30), -333},
+	}
+	if len(rs) != len(want) {
+		t.Fatalf("want %d slices, got %d", len(want), len(rs))
+	}
+	for i, s := range rs {
+		if !s.Time.Equal(want[i].t) || s.Amount != want[i].amount {
+			t.Errorf("#%d: want slice of %d at %s, got %d at %s", i, want[i].amount, want[i].t, s.Amount, s.Time)
+		}
+		if s.Slice == nil || s.Slice.N != i+1 || s.Slice.Of != 3 || s.Slice.Original.ID() != r.ID() {
+			t.Errorf("#%d: want slice %d/3 of %s, got %+v", i, i+1, r.ID(), s.Slice)
+		}
+	}
+	r.Time = date(2018, 1, 31) // Later slices are moved to the last day of shorter months
+	if got := r.Amortize(2)[1].Time; !got.Equal(date(2018, 2, 28)) {
+		t.Errorf("want slice at %s, got %s", date(2018, 2, 28), got)
+	}
+	if got := r.Amortize(1); len(got) != 1 || got[0].Slice != nil {
+		t.Errorf("want record unchanged, got %+v", got)
+	}
+}
+
 func TestAssortFunc(t *testing.T) {
 	rs := []Record{
 		{Time: date(2017, 1, 1), Text: "Foo 1", Amount: 42},
@@ -203,7 +234,7 @@ func TestGroupMath(t *testing.T) {
 			-500,     // sum
 			-500 * 3, // budget
 			-1000,    // balance
-			Range{date(2017, 1, 1), date(2017, 3, 1)}}, // range
+			Range{Since: date(2017, 1, 1), Until: date(2017, 3, 1)}}, // range
 		{Group{ // 2: Zero balance is considered balanced
 			budget: Budget{Months: [12]int64{500}},
 			Records: []Record{
@@ -237,8 +268,62 @@ func TestGroupMath(t *testing.T) {
 			-500, // sum
 			0,    // budget
 			500,  // balance
-			Range{date(2017, 2, 1), date(2017, 1, 1)}}, // range
-
+			Range{Since: date(2017, 2, 1), Until: date(2017, 1, 1)}}, // range
+		{Group{ // 5: Carried balance adds to the budget when computing balance
+			budget: Budget{Default: -1000},
+			Carry:  -300,
+			Records: []Record{
+				{Amount: -1200},
+			},
+		},
+			-1200, // sum
+			-1000, // budget
+			-100,  // balance
+			r},    // range
+		{Group{ // 6: Group without budget has the carried balance of its children
+			Children: []Group{
+				{budget: Budget{Default: -1000}, Carry: -300},
+				{budget: Budget{Default: -500}, Carry: 200},
+			},
+			Records: []Record{
+				{Amount: -1200},
+			},
+		},
+			-1200, // sum
+			-1500, // budget
+			-400,  // balance
+			r},    // range
+		{Group{ // 7: Budget periods replace the budget from the month containing their since time
+			budget: Budget{Default: -1000, Periods: []BudgetPeriod{
+				{Since: date(2017, 2, 15), Default: -2000},
+				{Since: date(2018, 1, 1), Months: [12]int64{-3000, -4000}},
+			}},
+		},
+			0,                               // sum
+			-1000 + 2000*-11 + -3000 - 4000, // budget
+			-1000 + 2000*-11 + -3000 - 4000, // balance
+			Range{Since: date(2017, 1, 1), Until: date(2018, 2, 1)}}, // range
+		{Group{ // 8: Yearly budget is prorated by days
+			budget: Budget{Default: -36500, Period: YearPeriod},
+		},
+			0,                   // sum
+			-3100 - 2800 - 1000, // budget
+			-3100 - 2800 - 1000, // balance
+			Range{Since: date(2017, 1, 1), Until: date(2017, 3, 10)}}, // range
+		{Group{ // 9: Budget of partial periods at both ends of range
+			budget: Budget{Default: -7000, Period: WeekPeriod},
+		},
+			0,                   // sum
+			-2000 - 7000 - 1000, // budget
+			-2000 - 7000 - 1000, // balance
+			Range{Since: date(2017, 1, 7), Until: date(2017, 1, 16)}}, // range
+		{Group{ // 10: Full quarter
+			budget: Budget{Default: -3000, Period: QuarterPeriod},
+		},
+			0,     // sum
+			-3000, // budget
+			-3000, // balance
+			Range{Since: date(2017, 4, 1), Until: date(2017, 6, 30)}}, // range
 	}
 	for i, tt := range tests {
 		if want, got := tt.sum, tt.g.Sum(); want != got {
@@ -253,6 +338,176 @@ func TestGroupMath(t *testing.T) {
 	}
 }
 
+func TestPeriodRange(t *testing.T) {
+	var tests = []struct {
+		period string
+		t      time.Time
+		want   Range
+	}{
+		{MonthPeriod, date(2017, 2, 15), Range{Since: date(2017, 2, 1), Until: date(2017, 2, 28)}},
+		{QuarterPeriod, date(2017, 6, 30), Range{Since: date(2017, 4, 1), Until: date(2017, 6, 30)}},
+		{QuarterPeriod, date(2017, 10, 1), Range{Since: date(2017, 10, 1), Until: date(2017, 12, 31)}},
+		{YearPeriod, date(2016, 7, 1), Range{Since: date(2016, 1, 1), Until: date(2016, 12, 31)}},
+		{WeekPeriod, date(2017, 1, 1), Range{Since: date(2016, 12, 26), Until: date(2017, 1, 1)}},
+		{WeekPeriod, date(2017, 1, 2), Range{Since: date(2017, 1, 2), Until: date(2017, 1, 8)}},
+	}
+	for i, tt := range tests {
+		if got := PeriodRange(tt.period, tt.t); got != tt.want {
+			t.Errorf("#%d: want %v, got %v", i, tt.want, got)
+		}
+	}
+}
+
+func TestUtilisation(t *testing.T) {
+	g := Group{budget: Budget{Default: -10000, Period: YearPeriod}, Spent: -2500}
+	if u, ok := g.Utilisation(date(2017, 5, 1)); !ok || u != 0.25 {
+		t.Errorf("want utilisation 0.25, got %f (%t)", u, ok)
+	}
+	var parent Group
+	if _, ok := parent.Utilisation(date(2017, 5, 1)); ok {
+		t.Error("want no utilisation for group without budget")
+	}
+}
+
+func TestProrate(t *testing.T) {
+	g := Group{
+		budget: Budget{Default: -3100},
+		Records: []Record{
+			{Time: date(2017, 1, 2), Amount: -500},
+			{Time: date(2017, 1, 4), Amount: -500},
+		},
+	}
+	r := Range{Since: date(2017, 1, 1), Until: date(2017, 1, 5)}
+	if want, got := int64(-3100), g.Budget(r); want != got {
+		t.Errorf("want Budget = %d, got %d", want, got)
+	}
+	r.Prorate = true
+	if want, got := int64(-500), g.Budget(r); want != got {
+		t.Errorf("want prorated Budget = %d, got %d", want, got)
+	}
+	if want, got := int64(-6200), g.Pace(r); want != got {
+		t.Errorf("want Pace = %d, got %d", want, got)
+	}
+	if !g.OverPace(r) {
+		t.Error("want group to be over pace")
+	}
+	r.Until = date(2017, 1, 10)
+	if want, got := int64(-3100), g.Pace(r); want != got {
+		t.Errorf("want Pace = %d, got %d", want, got)
+	}
+	if g.OverPace(r) {
+		t.Error("want group to be on pace")
+	}
+}
+
+func TestRollup(t *testing.T) {
+	gs := []Group{
+		{Name: "Food", Records: []Record{{Amount: 1}}},
+		{Name: "Food/Groceries", budget: Budget{Default: -100}, Records: []Record{{Amount: 2}, {Amount: 3}}},
+		{Name: "Food/Restaurants/Fast food", budget: Budget{Default: -50}, Records: []Record{{Amount: 4}}},
+		{Name: "Travel", Records: []Record{{Amount: 5}}},
+	}
+	newGroup := func(name string) Group {
+		if name == "Food/Restaurants" {
+			return Group{Name: name, budget: Budget{Default: -200}}
+		}
+		return Group{Name: name}
+	}
+	rgs := Rollup(gs, newGroup)
+	r := Range{Since: date(2017, 1, 1), Until: date(2017, 1, 1)}
+	var tests = []struct {
+		g        Group
+		name     string
+		depth    int
+		records  int
+		children int
+		sum      int64
+		budget   int64
+	}{
+		{rgs[0], "Food", 1, 4, 2, 10, -300},
+		{rgs[0].Children[0], "Food/Groceries", 2, 2, 0, 5, -100},
+		{rgs[0].Children[1], "Food/Restaurants", 2, 1, 1, 4, -200},
+		{rgs[0].Children[1].Children[0], "Food/Restaurants/Fast food", 3, 1, 0, 4, -50},
+		{rgs[1], "Travel", 1, 1, 0, 5, 0},
+	}
+	if want, got := 2, len(rgs); want != got {
+		t.Fatalf("want %d top-level groups, got %d", want, got)
+	}
+	for i, tt := range tests {
+		if tt.g.Name != tt.name {
+			t.Errorf("#%d: want Name = %q, got %q", i, tt.name, tt.g.Name)
+		}
+		if got := tt.g.Depth(); got != tt.depth {
+			t.Errorf("#%d: want Depth = %d, got %d", i, tt.depth, got)
+		}
+		if got := len(tt.g.Records); got != tt.records {
+			t.Errorf("#%d: want %d records, got %d", i, tt.records, got)
+		}
+		if got := len(tt.g.Children); got != tt.children {
+			t.Errorf("#%d: want %d children, got %d", i, tt.children, got)
+		}
+		if got := tt.g.Sum(); got != tt.sum {
+			t.Errorf("#%d: want Sum = %d, got %d", i, tt.sum, got)
+		}
+		if got := tt.g.Budget(r); got != tt.budget {
+			t.Errorf("#%d: want Budget = %d, got %d", i, tt.budget, got)
+		}
+	}
+	if want, got := "Fast food", rgs[0].Children[1].Children[0].BaseName(); want != got {
+		t.Errorf("want BaseName = %q, got %q", want, got)
+	}
+	for _, tt := range []struct {
+		name, group string
+		in          bool
+	}{
+		{"Food", "Food", true},
+		{"Food/Groceries", "Food", true},
+		{"Foodstuff", "Food", false},
+		{"Food", "Food/Groceries", false},
+	} {
+		if got := InGroup(tt.name, tt.group); got != tt.in {
+			t.Errorf("InGroup(%q, %q) = %t, want %t", tt.name, tt.group, got, tt.in)
+		}
+	}
+	if want, got := 2, len(gs[1].Records); want != got {
+		t.Errorf("want input group to be unchanged, got %d records", got)
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	gs := []Group{
+		{Name: "Food/Groceries", Records: []Record{{Amount: 1}}},
+		{Name: "Food/Restaurants/Fast food", Records: []Record{{Amount: 2}}},
+		{Name: "Food/Restaurants", Records: []Record{{Amount: 3}}},
+		{Name: "Travel", Records: []Record{{Amount: 4}}},
+	}
+	newGroup := func(name string) Group { return Group{Name: name} }
+	var tests = []struct {
+		depth int
+		names []string
+		sums  []int64
+	}{
+		{0, []string{"Food/Groceries", "Food/Restaurants/Fast food", "Food/Restaurants", "Travel"}, []int64{1, 2, 3, 4}},
+		{1, []string{"Food", "Travel"}, []int64{6, 4}},
+		{2, []string{"Food/Groceries", "Food/Restaurants", "Travel"}, []int64{1, 5, 4}},
+	}
+	for i, tt := range tests {
+		collapsed := Collapse(gs, tt.depth, newGroup)
+		var names []string
+		var sums []int64
+		for _, g := range collapsed {
+			names = append(names, g.Name)
+			sums = append(sums, g.Sum())
+		}
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Errorf("#%d: want names %q, got %q", i, tt.names, names)
+		}
+		if !reflect.DeepEqual(sums, tt.sums) {
+			t.Errorf("#%d: want sums %d, got %d", i, tt.sums, sums)
+		}
+	}
+}
+
 func TestMaxBalance(t *testing.T) {
 	var tests = []struct {
 		gs  []Group
@@ -264,7 +519,7 @@ func TestMaxBalance(t *testing.T) {
 			{Records: []Record{{Amount: -5000}, {Amount: -2000}}},
 		}, 8000},
 	}
-	r := Range{date(2017, 1, 1), date(2017, 2, 1)}
+	r := Range{Since: date(2017, 1, 1), Until: date(2017, 2, 1)}
 	for i, tt := range tests {
 		if got, want := MaxBalance(tt.gs, r), tt.max; got != want {
 			t.Errorf("#%d: want %d, got %d", i, want, got)
@@ -283,7 +538,7 @@ func TestMinBalance(t *testing.T) {
 			{Records: []Record{{Amount: 5000}, {Amount: 2000}}},
 		}, -8000},
 	}
-	r := Range{date(2017, 1, 1), date(2017, 2, 1)}
+	r := Range{Since: date(2017, 1, 1), Until: date(2017, 2, 1)}
 	for i, tt := range tests {
 		if got, want := MinBalance(tt.gs, r), tt.min; got != want {
 			t.Errorf("#%d: want %d, got %d", i, want, got)
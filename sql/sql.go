@@ -3,6 +3,7 @@ package sql
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,6 +16,11 @@ CREATE TABLE IF NOT EXISTS account (
   id INTEGER PRIMARY KEY,
   number TEXT NOT NULL,
   name TEXT NOT NULL,
+  type TEXT NOT NULL DEFAULT '',
+  institution TEXT NOT NULL DEFAULT '',
+  opening_balance INTEGER NOT NULL DEFAULT 0,
+  opening_time INTEGER NOT NULL DEFAULT 0,
+  closed INTEGER NOT NULL DEFAULT 0,
   CONSTRAINT number_unique UNIQUE (number)
 );
 
@@ -30,8 +36,54 @@ CREATE TABLE IF NOT EXISTS record (
 );
 
 CREATE INDEX IF NOT EXISTS record_time_idx ON record (time);
+
+CREATE TABLE IF NOT EXISTS group_rule (
+  id INTEGER PRIMARY KEY,
+  group_name TEXT NOT NULL,
+  kind TEXT NOT NULL,
+  value TEXT NOT NULL,
+  CONSTRAINT group_rule_unique UNIQUE (group_name, kind, value)
+);
+
+CREATE TABLE IF NOT EXISTS allocation (
+  id INTEGER PRIMARY KEY,
+  month INTEGER NOT NULL,
+  group_name TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  CONSTRAINT allocation_unique UNIQUE (month, group_name)
+);
+
+CREATE TABLE IF NOT EXISTS transfer (
+  id INTEGER PRIMARY KEY,
+  from_id INTEGER NOT NULL,
+  to_id INTEGER NOT NULL,
+  CONSTRAINT transfer_from_unique UNIQUE (from_id),
+  CONSTRAINT transfer_to_unique UNIQUE (to_id),
+  FOREIGN KEY(from_id) REFERENCES record(id) ON DELETE CASCADE,
+  FOREIGN KEY(to_id) REFERENCES record(id) ON DELETE CASCADE
+);
 `
 
+const (
+	// GroupDeclaration is the kind of a group rule that declares a group without matching any records.
+	GroupDeclaration = "group"
+
+	// PatternRule is the kind of a group rule matching records by pattern.
+	PatternRule = "pattern"
+
+	// PinRule is the kind of a group rule matching a single record by its ID.
+	PinRule = "id"
+)
+
+// accountColumns contains columns that have been added to the account table after its initial version.
+var accountColumns = []struct{ name, definition string }{
+	{"type", "TEXT NOT NULL DEFAULT ''"},
+	{"institution", "TEXT NOT NULL DEFAULT ''"},
+	{"opening_balance", "INTEGER NOT NULL DEFAULT 0"},
+	{"opening_time", "INTEGER NOT NULL DEFAULT 0"},
+	{"closed", "INTEGER NOT NULL DEFAULT 0"},
+}
+
 // Client implements a client for a SQLite database.
 type Client struct {
 	db *sqlx.DB
@@ -40,20 +92,48 @@ type Client struct {
 
 // Account represents a financial account.
 type Account struct {
-	Number  string `db:"number"`
-	Name    string `db:"name"`
-	Records int64  `db:"records"`
+	Number         string `db:"number"`
+	Name           string `db:"name"`
+	Type           string `db:"type"`
+	Institution    string `db:"institution"`
+	OpeningBalance int64  `db:"opening_balance"`
+	OpeningTime    int64  `db:"opening_time"`
+	Closed         bool   `db:"closed"`
+	Records        int64  `db:"records"`
+	Sum            int64  `db:"sum"`
 }
 
 // Record represents a single financial record.
 type Record struct {
-	Time    int64  `db:"time"`
-	Text    string `db:"text"`
-	Amount  int64  `db:"amount"`
-	Balance int64  `db:"balance"`
+	ID       int64  `db:"id"`
+	Time     int64  `db:"time"`
+	Text     string `db:"text"`
+	Amount   int64  `db:"amount"`
+	Balance  int64  `db:"balance"`
+	Transfer bool   `db:"transfer"` // Whether this record is part of a transfer
 	Account
 }
 
+// GroupRule represents a rule for assorting records into a group.
+type GroupRule struct {
+	Group string `db:"group_name"`
+	Kind  string `db:"kind"`
+	Value string `db:"value"`
+}
+
+// Allocation represents an amount allocated to a group in a given month.
+type Allocation struct {
+	Month  int64  `db:"month"`
+	Group  string `db:"group_name"`
+	Amount int64  `db:"amount"`
+}
+
+// Transfer represents a pair of records moving money between two accounts. Records are identified by their row ID.
+type Transfer struct {
+	From int64 `db:"from_id"`
+	To   int64 `db:"to_id"`
+}
+
 // New creates a new database client for given filename.
 func New(filename string) (*Client, error) {
 	db, err := sqlx.Connect("sqlite3", filename)
@@ -67,9 +147,32 @@ func New(filename string) (*Client, error) {
 	if _, err := db.Exec(schema); err != nil {
 		return nil, err
 	}
+	if err := migrate(db); err != nil {
+		return nil, err
+	}
 	return &Client{db: db}, nil
 }
 
+func migrate(db *sqlx.DB) error {
+	var columns []string
+	if err := db.Select(&columns, "SELECT name FROM pragma_table_info('account')"); err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for _, c := range columns {
+		existing[c] = true
+	}
+	for _, c := range accountColumns {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := db.Exec("ALTER TABLE account ADD COLUMN " + c.name + " " + c.definition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func rowsAffected(result sql.Result) int64 {
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
@@ -79,7 +182,8 @@ func rowsAffected(result sql.Result) int64 {
 	return rowsAffected
 }
 
-// AddAccounts writes accounts to the database and returns the number of changed rows.
+// AddAccounts writes accounts to the database and returns the number of created rows. Metadata of existing accounts
+// is updated, but their name is kept.
 func (c *Client) AddAccounts(accounts []Account) (int64, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -95,9 +199,16 @@ func (c *Client) AddAccounts(accounts []Account) (int64, error) {
 			return 0, err
 		}
 		if count > 0 {
+			if _, err := tx.Exec(`
+UPDATE account SET type = $1, institution = $2, opening_balance = $3, opening_time = $4, closed = $5
+WHERE number = $6`, a.Type, a.Institution, a.OpeningBalance, a.OpeningTime, a.Closed, a.Number); err != nil {
+				return 0, err
+			}
 			continue
 		}
-		res, err := tx.Exec("INSERT INTO account (number, name) VALUES ($1, $2)", a.Number, a.Name)
+		res, err := tx.Exec(`
+INSERT INTO account (number, name, type, institution, opening_balance, opening_time, closed)
+VALUES ($1, $2, $3, $4, $5, $6, $7)`, a.Number, a.Name, a.Type, a.Institution, a.OpeningBalance, a.OpeningTime, a.Closed)
 		if err != nil {
 			return 0, err
 		}
@@ -107,13 +218,15 @@ func (c *Client) AddAccounts(accounts []Account) (int64, error) {
 }
 
 // SelectAccounts reads accounts from the database matching accountNumber. If accountNumber is an empty string, all
-// accounts are returned.
+// accounts are returned. The sum of each account includes records occurring at or after its opening time.
 func (c *Client) SelectAccounts(accountNumber string) ([]Account, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	var as []Account
 	query := `
-SELECT number, name, COUNT(record.id) AS records
+SELECT number, name, type, institution, opening_balance, opening_time, closed,
+       COUNT(record.id) AS records,
+       COALESCE(SUM(CASE WHEN record.time >= opening_time THEN record.amount ELSE 0 END), 0) AS sum
 FROM account
 LEFT JOIN record ON account.id = record.account_id
 `
@@ -127,6 +240,184 @@ LEFT JOIN record ON account.id = record.account_id
 	return as, err
 }
 
+// RenameAccount sets the name of the account identified by accountNumber and returns the number of changed rows.
+func (c *Client) RenameAccount(accountNumber, name string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, err := c.db.Exec("UPDATE account SET name = $1 WHERE number = $2", name, accountNumber)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(res), nil
+}
+
+// DeleteAccount deletes the account identified by accountNumber, including all of its records. The number of deleted
+// records is returned.
+func (c *Client) DeleteAccount(accountNumber string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	accountID := 0
+	if err := tx.Get(&accountID, "SELECT id FROM account WHERE number = $1 LIMIT 1", accountNumber); err != nil {
+		return 0, fmt.Errorf("invalid account: %s: %w", accountNumber, err)
+	}
+	res, err := tx.Exec("DELETE FROM record WHERE account_id = $1", accountID)
+	if err != nil {
+		return 0, err
+	}
+	rows := rowsAffected(res)
+	if _, err := tx.Exec("DELETE FROM account WHERE id = $1", accountID); err != nil {
+		return 0, err
+	}
+	return rows, tx.Commit()
+}
+
+// MergeAccounts moves all records belonging to account fromNumber to account toNumber, and deletes account
+// fromNumber. Records that already exist in account toNumber are dropped. The number of moved records is returned.
+func (c *Client) MergeAccounts(fromNumber, toNumber string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	fromID, toID := 0, 0
+	if err := tx.Get(&fromID, "SELECT id FROM account WHERE number = $1 LIMIT 1", fromNumber); err != nil {
+		return 0, fmt.Errorf("invalid account: %s: %w", fromNumber, err)
+	}
+	if err := tx.Get(&toID, "SELECT id FROM account WHERE number = $1 LIMIT 1", toNumber); err != nil {
+		return 0, fmt.Errorf("invalid account: %s: %w", toNumber, err)
+	}
+	if fromID == toID {
+		return 0, fmt.Errorf("cannot merge account %s into itself", fromNumber)
+	}
+	res, err := tx.Exec("UPDATE OR IGNORE record SET account_id = $1 WHERE account_id = $2", toID, fromID)
+	if err != nil {
+		return 0, err
+	}
+	rows := rowsAffected(res)
+	// Any remaining records are duplicates of records in the target account
+	if _, err := tx.Exec("DELETE FROM record WHERE account_id = $1", fromID); err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec("DELETE FROM account WHERE id = $1", fromID); err != nil {
+		return 0, err
+	}
+	return rows, tx.Commit()
+}
+
+// AddGroupRule writes rule to the database and returns the number of created rows. An existing identical rule is
+// ignored.
+func (c *Client) AddGroupRule(rule GroupRule) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, err := c.db.Exec("INSERT OR IGNORE INTO group_rule (group_name, kind, value) VALUES ($1, $2, $3)",
+		rule.Group, rule.Kind, rule.Value)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(res), nil
+}
+
+// SelectGroupRules returns all group rules, in the order they were added.
+func (c *Client) SelectGroupRules() ([]GroupRule, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var rules []GroupRule
+	err := c.db.Select(&rules, "SELECT group_name, kind, value FROM group_rule ORDER BY id ASC")
+	return rules, err
+}
+
+// DeleteGroup deletes all rules belonging to group and returns the number of deleted rows.
+func (c *Client) DeleteGroup(group string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, err := c.db.Exec("DELETE FROM group_rule WHERE group_name = $1", group)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(res), nil
+}
+
+// DeleteGroupRules deletes rules of given kind and value from all groups, and returns the number of deleted rows.
+func (c *Client) DeleteGroupRules(kind, value string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res, err := c.db.Exec("DELETE FROM group_rule WHERE kind = $1 AND value = $2", kind, value)
+	if err != nil {
+		return 0, err
+	}
+	return rowsAffected(res), nil
+}
+
+// SetAllocation writes allocation to the database, replacing any existing allocation to the same group in the same
+// month. An allocation with a zero amount deletes the existing allocation.
+func (c *Client) SetAllocation(allocation Allocation) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var err error
+	if allocation.Amount == 0 {
+		_, err = c.db.Exec("DELETE FROM allocation WHERE month = $1 AND group_name = $2", allocation.Month, allocation.Group)
+	} else {
+		_, err = c.db.Exec("INSERT OR REPLACE INTO allocation (month, group_name, amount) VALUES ($1, $2, $3)",
+			allocation.Month, allocation.Group, allocation.Amount)
+	}
+	return err
+}
+
+// SelectAllocations returns all allocations, ordered by month and group.
+func (c *Client) SelectAllocations() ([]Allocation, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var allocations []Allocation
+	err := c.db.Select(&allocations, "SELECT month, group_name, amount FROM allocation ORDER BY month ASC, group_name ASC")
+	return allocations, err
+}
+
+// AddTransfers writes transfers to the database and returns the number of created rows. Transfers involving a record
+// that is already part of a transfer are ignored.
+func (c *Client) AddTransfers(transfers []Transfer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var rows int64
+	for _, t := range transfers {
+		res, err := tx.Exec("INSERT OR IGNORE INTO transfer (from_id, to_id) VALUES ($1, $2)", t.From, t.To)
+		if err != nil {
+			return 0, err
+		}
+		rows += rowsAffected(res)
+	}
+	return rows, tx.Commit()
+}
+
+// LastRecordID returns the row ID of the most recently added record, or zero if there are no records.
+func (c *Client) LastRecordID() (int64, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var id int64
+	err := c.db.Get(&id, "SELECT COALESCE(MAX(id), 0) FROM record")
+	return id, err
+}
+
+// SelectTransfers returns all transfers, in the order they were added.
+func (c *Client) SelectTransfers() ([]Transfer, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var transfers []Transfer
+	err := c.db.Select(&transfers, "SELECT from_id, to_id FROM transfer ORDER BY id ASC")
+	return transfers, err
+}
+
 // AddRecords writes new records to belonging to accountNumber to the database, and returns the number of changed rows.
 // Any duplicate records are ignored.
 func (c *Client) AddRecords(accountNumber string, records []Record) (int64, error) {
@@ -173,34 +464,46 @@ VALUES ($1, $2, $3, $4, $5)
 	return rows, tx.Commit()
 }
 
-// SelectRecords reads all records belonging to given accountNumber.
+// SelectRecords reads all records belonging to given accountNumber. If accountNumber is an empty string, records for
+// all accounts are returned.
 func (c *Client) SelectRecords(accountNumber string) ([]Record, error) {
-	return c.SelectRecordsBetween(accountNumber, time.Time{}, time.Time{})
+	var accountNumbers []string
+	if accountNumber != "" {
+		accountNumbers = []string{accountNumber}
+	}
+	return c.SelectRecordsBetween(accountNumbers, time.Time{}, time.Time{})
 }
 
-// SelectRecordsBetween reads all records belonging to given accountNumber, and occurring between the times since and
-// until.
-func (c *Client) SelectRecordsBetween(accountNumber string, since, until time.Time) ([]Record, error) {
+// SelectRecordsBetween reads all records belonging to any of accountNumbers, and occurring between the times since and
+// until. If accountNumbers is empty, records for all accounts are returned.
+func (c *Client) SelectRecordsBetween(accountNumbers []string, since, until time.Time) ([]Record, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	query := `
-SELECT name, number, time, text, amount, balance
+SELECT record.id AS id, name, number, type, institution, opening_balance, opening_time, closed, time, text, amount,
+       balance, EXISTS (SELECT 1 FROM transfer WHERE from_id = record.id OR to_id = record.id) AS transfer
 FROM record
 INNER JOIN account ON account_id = account.id
 `
+	var conditions []string
 	args := []any{}
-	if accountNumber != "" {
-		query += " WHERE number = ?"
-		args = append(args, accountNumber)
+	if len(accountNumbers) > 0 {
+		conditions = append(conditions, "number IN (?"+strings.Repeat(", ?", len(accountNumbers)-1)+")")
+		for _, n := range accountNumbers {
+			args = append(args, n)
+		}
 	}
 	if !since.IsZero() {
-		query += " AND time >= ?"
+		conditions = append(conditions, "time >= ?")
 		args = append(args, since.Unix())
 	}
 	if !until.IsZero() {
-		query += " AND time <= ?"
+		conditions = append(conditions, "time <= ?")
 		args = append(args, until.Unix())
 	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
 	query += " ORDER BY time DESC"
 	var rs []Record
 	if err := c.db.Select(&rs, query, args...); err != nil {
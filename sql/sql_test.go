@@ -1,8 +1,12 @@
 package sql
 
 import (
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
+
+	"github.com/jmoiron/sqlx"
 )
 
 func testClient() *Client {
@@ -106,7 +110,7 @@ func TestAddRecords(t *testing.T) {
 	// Select records in date range
 	since := date(2017, 2, 10)
 	until := date(2017, 3, 15)
-	rs, err = c.SelectRecordsBetween(number, since, until)
+	rs, err = c.SelectRecordsBetween([]string{number}, since, until)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -129,3 +133,305 @@ func TestAddRecords(t *testing.T) {
 		t.Errorf("want len = %d, got %d", want, got)
 	}
 }
+
+func TestRenameAccount(t *testing.T) {
+	c := testClient()
+	if _, err := c.AddAccounts([]Account{{Number: "1.2.3", Name: "Account 1"}}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := c.RenameAccount("1.2.3", "Savings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(1); n != want {
+		t.Errorf("want %d rows, got %d", want, n)
+	}
+	as, err := c.SelectAccounts("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, got := "Savings", as[0].Name; want != got {
+		t.Errorf("want Name = %q, got %q", want, got)
+	}
+	if n, err := c.RenameAccount("4.5.6", "Savings"); err != nil || n != 0 {
+		t.Errorf("want 0 rows for unknown account, got %d (err = %v)", n, err)
+	}
+}
+
+func TestDeleteAccount(t *testing.T) {
+	c := testClient()
+	if _, err := c.AddAccounts([]Account{{Number: "1.2.3", Name: "Account 1"}, {Number: "4.5.6", Name: "Account 2"}}); err != nil {
+		t.Fatal(err)
+	}
+	rs := []Record{
+		{Time: date(2017, 1, 1).Unix(), Text: "Transaction 1", Amount: 42},
+		{Time: date(2017, 1, 2).Unix(), Text: "Transaction 2", Amount: 42},
+	}
+	if _, err := c.AddRecords("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddRecords("4.5.6", rs[:1]); err != nil {
+		t.Fatal(err)
+	}
+	n, err := c.DeleteAccount("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(2); n != want {
+		t.Errorf("want %d deleted records, got %d", want, n)
+	}
+	as, err := c.SelectAccounts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 || as[0].Number != "4.5.6" || as[0].Records != 1 {
+		t.Errorf("want only account 4.5.6 with 1 record, got %+v", as)
+	}
+	if _, err := c.DeleteAccount("1.2.3"); err == nil {
+		t.Error("want error when deleting unknown account")
+	}
+}
+
+func TestMergeAccounts(t *testing.T) {
+	c := testClient()
+	if _, err := c.AddAccounts([]Account{{Number: "1.2.3", Name: "Old"}, {Number: "4.5.6", Name: "New"}}); err != nil {
+		t.Fatal(err)
+	}
+	rs := []Record{
+		{Time: date(2017, 1, 1).Unix(), Text: "Transaction 1", Amount: 42},
+		{Time: date(2017, 1, 2).Unix(), Text: "Transaction 2", Amount: 42},
+	}
+	if _, err := c.AddRecords("1.2.3", rs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddRecords("4.5.6", rs[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.MergeAccounts("1.2.3", "1.2.3"); err == nil {
+		t.Error("want error when merging account into itself")
+	}
+	n, err := c.MergeAccounts("1.2.3", "4.5.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(1); n != want {
+		t.Errorf("want %d moved records, got %d", want, n)
+	}
+	as, err := c.SelectAccounts("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 || as[0].Number != "4.5.6" || as[0].Records != 2 {
+		t.Errorf("want only account 4.5.6 with 2 records, got %+v", as)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "db")
+	db, err := sqlx.Connect("sqlite3", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE account (id INTEGER PRIMARY KEY, number TEXT NOT NULL, name TEXT NOT NULL)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("INSERT INTO account (number, name) VALUES ('1.2.3', 'Account 1')"); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	c, err := New(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.AddAccounts([]Account{{Number: "1.2.3", Type: "savings", Closed: true}}); err != nil {
+		t.Fatal(err)
+	}
+	as, err := c.SelectAccounts("1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 || as[0].Name != "Account 1" || as[0].Type != "savings" || !as[0].Closed {
+		t.Errorf("want migrated account with metadata, got %+v", as)
+	}
+}
+
+func TestSelectRecordsBetweenAccounts(t *testing.T) {
+	c := testClient()
+	as := []Account{{Number: "1.2.3"}, {Number: "4.5.6"}, {Number: "7.8.9"}}
+	if _, err := c.AddAccounts(as); err != nil {
+		t.Fatal(err)
+	}
+	for i, a := range as {
+		rs := []Record{
+			{Time: date(2017, 1, 1).Unix(), Text: "Transaction 1", Amount: int64(i)},
+			{Time: date(2017, 2, 1).Unix(), Text: "Transaction 2", Amount: int64(i)},
+		}
+		if _, err := c.AddRecords(a.Number, rs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var tests = []struct {
+		accounts []string
+		since    time.Time
+		n        int
+	}{
+		{nil, time.Time{}, 6},
+		{nil, date(2017, 2, 1), 3},
+		{[]string{"1.2.3"}, time.Time{}, 2},
+		{[]string{"1.2.3", "7.8.9"}, time.Time{}, 4},
+		{[]string{"1.2.3", "7.8.9"}, date(2017, 2, 1), 2},
+	}
+	for i, tt := range tests {
+		rs, err := c.SelectRecordsBetween(tt.accounts, tt.since, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := len(rs); got != tt.n {
+			t.Errorf("#%d: want %d records, got %d", i, tt.n, got)
+		}
+	}
+}
+
+func TestGroupRules(t *testing.T) {
+	c := testClient()
+	rules := []GroupRule{
+		{Group: "Groceries", Kind: PatternRule, Value: "^Rema"},
+		{Group: "Travel", Kind: GroupDeclaration},
+		{Group: "Groceries", Kind: PinRule, Value: "45defdf469"},
+		{Group: "Groceries", Kind: PatternRule, Value: "^Rema"}, // Duplicate
+		{Group: "Travel", Kind: PinRule, Value: "45defdf469"},
+	}
+	var added int64
+	for _, r := range rules {
+		n, err := c.AddGroupRule(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		added += n
+	}
+	if want := int64(4); added != want {
+		t.Errorf("want %d rows, got %d", want, added)
+	}
+	got, err := c.SelectGroupRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []GroupRule{rules[0], rules[1], rules[2], rules[4]}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	if n, err := c.DeleteGroupRules(PinRule, "45defdf469"); err != nil || n != 2 {
+		t.Errorf("want 2 deleted pins, got %d (err = %v)", n, err)
+	}
+	if n, err := c.DeleteGroup("Groceries"); err != nil || n != 1 {
+		t.Errorf("want 1 deleted rule, got %d (err = %v)", n, err)
+	}
+	got, err = c.SelectGroupRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []GroupRule{rules[1]}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestAllocations(t *testing.T) {
+	c := testClient()
+	allocations := []Allocation{
+		{Month: date(2018, 2, 1).Unix(), Group: "Groceries", Amount: 5000},
+		{Month: date(2018, 1, 1).Unix(), Group: "Groceries", Amount: 4000},
+		{Month: date(2018, 1, 1).Unix(), Group: "Travel", Amount: 1000},
+		{Month: date(2018, 2, 1).Unix(), Group: "Groceries", Amount: 6000}, // Replaces existing allocation
+	}
+	for _, a := range allocations {
+		if err := c.SetAllocation(a); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := c.SelectAllocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Allocation{allocations[1], allocations[2], allocations[3]}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+
+	if err := c.SetAllocation(Allocation{Month: date(2018, 1, 1).Unix(), Group: "Travel"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = c.SelectAllocations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Allocation{allocations[1], allocations[3]}; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestTransfers(t *testing.T) {
+	c := testClient()
+	as := []Account{{Number: "1.2.3", Name: "Checking"}, {Number: "4.5.6", Name: "Savings"}}
+	if _, err := c.AddAccounts(as); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range as {
+		rs := []Record{
+			{Time: date(2017, 1, 1).Unix(), Text: "Transaction 1", Amount: 42},
+			{Time: date(2017, 1, 2).Unix(), Text: "Transaction 2", Amount: 42},
+		}
+		if _, err := c.AddRecords(a.Number, rs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	last, err := c.LastRecordID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(4); last != want {
+		t.Errorf("want last record ID %d, got %d", want, last)
+	}
+	transfers := []Transfer{
+		{From: 1, To: 3},
+		{From: 2, To: 4},
+		{From: 1, To: 4}, // From is already part of a transfer
+		{From: 3, To: 4}, // To is already part of a transfer
+	}
+	n, err := c.AddTransfers(transfers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(2); n != want {
+		t.Errorf("want %d rows, got %d", want, n)
+	}
+	got, err := c.SelectTransfers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := transfers[:2]; !reflect.DeepEqual(want, got) {
+		t.Errorf("want %+v, got %+v", want, got)
+	}
+	rs, err := c.SelectRecords("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rs {
+		if !r.Transfer {
+			t.Errorf("want record %d to be part of a transfer", r.ID)
+		}
+	}
+
+	// Transfers are removed with their records
+	if _, err := c.DeleteAccount("4.5.6"); err != nil {
+		t.Fatal(err)
+	}
+	got, err = c.SelectTransfers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("want 0 transfers, got %+v", got)
+	}
+}